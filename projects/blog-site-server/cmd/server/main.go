@@ -1,70 +1,365 @@
 package main
 
 import (
+	"blog-app/internal/auth"
+	"blog-app/internal/config"
 	"blog-app/internal/db"
 	"blog-app/internal/handlers"
+	"blog-app/internal/logging"
+	"blog-app/internal/metrics"
+	"blog-app/internal/models"
 	"blog-app/internal/repository"
 	"blog-app/internal/routes"
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
 	"github.com/joho/godotenv"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 )
 
 func main() {
 	// Loading environment variables from .env file
-	err := godotenv.Load()
+	dotenvErr := godotenv.Load()
+
+	// Settings come from defaults, an optional config file, the
+	// environment and flags, in increasing precedence
+	cfg, err := config.Load(os.Args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
 	if err != nil {
-		log.Println("No .env file found or error loading .env file")
+		fmt.Fprintf(os.Stderr, "Invalid configuration:\n%v\n", err)
+		os.Exit(2)
+	}
+	if cfg.PrintConfig {
+		if err := cfg.Print(os.Stdout); err != nil {
+			fatal("Failed to print configuration", err)
+		}
+		return
 	}
 
-	// Initialize database connection
-	if err := db.InitializeDB(); err != nil {
-		log.Fatal("Failed to initialize database:", err)
+	logger, err := logging.New(os.Stderr, cfg.Log.Format, cfg.Log.Level)
+	if err != nil {
+		fatal("Failed to set up logging", err)
 	}
-	defer db.CloseDB()
+	slog.SetDefault(logger)
+	if dotenvErr != nil {
+		slog.Debug("No .env file loaded", "error", dotenvErr)
+	}
+
+	// Select the storage backend: Postgres by default, memory for a
+	// throwaway in-process store
+	var (
+		blogRepo    repository.BlogStore
+		tagRepo     repository.TagStore
+		userRepo    repository.UserStore
+		commentRepo repository.CommentStore
+		sessionRepo repository.SessionStore
+		database    *sql.DB
+	)
+
+	switch cfg.Storage {
+	case "postgres":
+		// Initialize database connection, waiting for Postgres to come up
+		database, err = db.Open(context.Background(), cfg.Database)
+		if err != nil {
+			fatal("Failed to initialize database", err)
+		}
 
-	// Get database instance
-	database := db.GetDB()
+		// "server migrate up|down|status" manages the schema and exits
+		if len(cfg.Args) > 0 && cfg.Args[0] == "migrate" {
+			if err := runMigrate(database, cfg.Args[1:]); err != nil {
+				db.Close(database)
+				fatal("Migration failed", err)
+			}
+			return
+		}
 
-	// Initialize repositories
-	blogRepo := repository.NewBlogRepository(database)
-	userRepo := repository.NewUserRepository(database)
-	commentRepo := repository.NewCommentRepository(database)
+		// Apply pending migrations on startup when asked to
+		ctx := context.Background()
+		if cfg.Database.AutoMigrate {
+			applied, err := db.MigrateUp(ctx, database)
+			if err != nil {
+				db.Close(database)
+				fatal("Failed to apply migrations", err)
+			}
+			slog.Info("Applied migrations", "count", applied)
+		}
+
+		// Refuse to run against a schema newer than this binary
+		pending, err := db.CheckSchema(ctx, database)
+		if err != nil {
+			db.Close(database)
+			fatal("Failed to check database schema", err)
+		}
+		if pending > 0 {
+			slog.Warn("Pending migrations; run \"migrate up\" or set AUTO_MIGRATE=true", "count", pending)
+		}
+
+		// Initialize repositories; each call is cancelled after the
+		// query timeout
+		queryTimeout := cfg.Database.QueryTimeout
+		blogRepo = repository.NewBlogRepository(database, queryTimeout)
+		tagRepo = repository.NewTagRepository(database, queryTimeout)
+		userRepo = repository.NewUserRepository(database, queryTimeout)
+		commentRepo = repository.NewCommentRepository(database, queryTimeout)
+		sessionRepo = repository.NewSessionRepository(database, queryTimeout)
+	case "memory":
+		if len(cfg.Args) > 0 && cfg.Args[0] == "migrate" {
+			fatal("Cannot run migrations", errors.New("the migrate command requires STORAGE=postgres"))
+		}
+
+		slog.Warn("Using in-memory storage; data will be lost on exit")
+		memDB := repository.NewMemoryDB()
+		blogRepo = repository.NewMemoryBlogRepository(memDB)
+		tagRepo = repository.NewMemoryTagRepository(memDB)
+		userRepo = repository.NewMemoryUserRepository(memDB)
+		commentRepo = repository.NewMemoryCommentRepository(memDB)
+		sessionRepo = repository.NewMemorySessionRepository(memDB)
+	}
 
 	// Initialize handlers
 	blogHandler := handlers.NewBlogHandler(blogRepo)
-	userHandler := handlers.NewUserHandler(userRepo)
-	commentHandler := handlers.NewCommentHandler(commentRepo)
+	tagHandler := handlers.NewTagHandler(tagRepo, blogRepo)
+	hasher := auth.NewPasswordHasher(cfg.Auth.HashIterations)
+	userHandler := handlers.NewUserHandler(userRepo, sessionRepo, hasher, cfg.Auth.Password)
+	if err := ensureBootstrapAdmin(context.Background(), userRepo, hasher, cfg.Bootstrap); err != nil {
+		fatal("Failed to create bootstrap admin", err)
+	}
+	commentHandler := handlers.NewCommentHandler(commentRepo, blogRepo, cfg.Comments.MaxTreeDepth)
+	authHandler := handlers.NewAuthHandler(userRepo, sessionRepo, hasher, cfg.Auth.SessionTTL)
+
+	// Metrics are scraped from the main port by admins, or from a separate
+	// listener that is expected to be reachable only internally
+	registry := metrics.NewRegistry()
+	metrics.RegisterRuntime(registry)
+	if database != nil {
+		metrics.RegisterDBStats(registry, database)
+	}
+	healthHandler := handlers.NewHealthHandler(database, cfg.Storage, registry)
 
-	// Setup routes
-	mux := routes.Setup(blogHandler, userHandler, commentHandler)
+	// Setup routes wrapped in the middleware stack
+	handler := routes.Setup(blogHandler, tagHandler, userHandler, commentHandler, authHandler, healthHandler, routes.Options{
+		Sessions:     sessionRepo,
+		Users:        userRepo,
+		Metrics:      registry,
+		ServeMetrics: cfg.Metrics.Address == "",
+		CORS:         cfg.CORS,
+		MaxBodyBytes: int64(cfg.Server.MaxBodyBytes),
+		RateLimit:    cfg.RateLimit,
+	})
 
 	// Starting the server
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
-
-	log.Printf("Starting blog site server on port %s...\n", port)
-	log.Println("Available endpoints:")
-	log.Println("  POST   /blogs")
-	log.Println("  GET    /blogs")
-	log.Println("  GET    /blogs/{id}")
-	log.Println("  PUT    /blogs/{id}")
-	log.Println("  DELETE /blogs/{id}")
-	log.Println("  POST   /users")
-	log.Println("  GET    /users")
-	log.Println("  GET    /users/{id}")
-	log.Println("  PUT    /users/{id}")
-	log.Println("  DELETE /users/{id}")
-	log.Println("  POST   /comments")
-	log.Println("  GET    /blogs/{blogID}/comments")
-	log.Println("  GET    /comments/{id}")
-	log.Println("  PUT    /comments/{id}")
-	log.Println("  DELETE /comments/{id}")
-
-	if err := http.ListenAndServe(":"+port, mux); err != nil {
-		log.Fatal("Server failed to start:", err)
+	server := &http.Server{
+		Addr:              fmt.Sprintf(":%d", cfg.Server.Port),
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+		MaxHeaderBytes:    cfg.Server.MaxHeaderBytes,
+	}
+	servers := []*http.Server{server}
+
+	if cfg.Metrics.Address != "" {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("GET /metrics", registry.Handler())
+		servers = append(servers, &http.Server{
+			Addr:              cfg.Metrics.Address,
+			Handler:           metricsMux,
+			ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+			ReadTimeout:       cfg.Server.ReadTimeout,
+			WriteTimeout:      cfg.Server.WriteTimeout,
+			IdleTimeout:       cfg.Server.IdleTimeout,
+			MaxHeaderBytes:    cfg.Server.MaxHeaderBytes,
+		})
+		slog.Info("Serving metrics", "address", cfg.Metrics.Address)
+	}
+
+	slog.Info("Starting blog site server", "port", cfg.Server.Port)
+	endpoints := []string{
+		"GET /healthz",
+		"GET /readyz",
+		"GET /debug/status",
+		"POST /auth/login",
+		"POST /auth/logout",
+		"POST /blogs",
+		"GET /blogs",
+		"GET /blogs/search",
+		"GET /blogs/{id}",
+		"PUT /blogs/{id}",
+		"PATCH /blogs/{id}",
+		"DELETE /blogs/{id}",
+		"GET /tags",
+		"GET /tags/{slug}/blogs",
+		"POST /users",
+		"GET /users",
+		"GET /users/{id}",
+		"PUT /users/{id}",
+		"PATCH /users/{id}",
+		"PUT /users/{id}/password",
+		"DELETE /users/{id}",
+		"POST /comments",
+		"GET /blogs/{blogID}/comments",
+		"GET /comments/{id}",
+		"PUT /comments/{id}",
+		"PATCH /comments/{id}",
+		"DELETE /comments/{id}",
+	}
+	if cfg.Metrics.Address == "" {
+		endpoints = append(endpoints, "GET /metrics")
+	}
+	for _, endpoint := range endpoints {
+		slog.Debug("Serving endpoint", "route", endpoint)
+	}
+
+	// The pool is closed only once no handler can use it any more
+	err = serve(cfg.Server.ShutdownDelay, cfg.Server.ShutdownGracePeriod, healthHandler.ShuttingDown, servers...)
+	if database != nil {
+		db.Close(database)
+	}
+	if err != nil {
+		fatal("Server stopped with error", err)
+	}
+	slog.Info("Server stopped")
+}
+
+// fatal logs err and exits with a failure status
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+// serve runs the servers until SIGINT or SIGTERM, or until one of them
+// fails. On a signal it calls stopping and keeps serving for delay so that
+// load balancers see readiness fail before the listeners close. It then
+// stops accepting connections and waits up to grace for in-flight requests
+// to finish. Requests still running after that are cut off and an error is
+// returned.
+func serve(delay, grace time.Duration, stopping func(), servers ...*http.Server) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errs := make(chan error, len(servers))
+	for _, server := range servers {
+		go func() {
+			errs <- server.ListenAndServe()
+		}()
+	}
+
+	var failure error
+	select {
+	case failure = <-errs:
+	case <-ctx.Done():
+	}
+	// Restore default handling so a second signal kills the process
+	stop()
+	stopping()
+	if failure == nil && delay > 0 {
+		slog.Info("Shutting down; failing readiness before draining", "delay", delay.String())
+		time.Sleep(delay)
+	}
+	slog.Info("Shutting down; draining in-flight requests", "grace_period", grace.String())
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+	drained := true
+	for _, server := range servers {
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			server.Close()
+			drained = false
+		}
+	}
+
+	if failure != nil {
+		return failure
+	}
+	if !drained {
+		return fmt.Errorf("requests did not drain within %s", grace)
+	}
+	return nil
+}
+
+// runMigrate executes the migrate subcommand
+func runMigrate(database *sql.DB, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: %s migrate up|down|status", os.Args[0])
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		applied, err := db.MigrateUp(ctx, database)
+		if err != nil {
+			return err
+		}
+		slog.Info("Applied migrations", "count", applied)
+	case "down":
+		reverted, err := db.MigrateDown(ctx, database)
+		if err != nil {
+			return err
+		}
+		if !reverted {
+			slog.Info("No migrations to revert")
+		}
+	case "status":
+		statuses, err := db.Status(ctx, database)
+		if err != nil {
+			return err
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.AppliedAt != nil {
+				state = "applied " + s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d  %-24s %s\n", s.Version, s.Name, state)
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %q, expected up, down or status", args[0])
+	}
+	return nil
+}
+
+// ensureBootstrapAdmin creates the configured admin account if it does not
+// exist yet, so that a fresh deployment has someone able to assign roles
+func ensureBootstrapAdmin(ctx context.Context, users repository.UserStore, hasher *auth.PasswordHasher, cfg config.Bootstrap) error {
+	username := cfg.AdminUsername
+	if username == "" {
+		return nil
+	}
+
+	if _, err := users.GetByUsername(ctx, username); err == nil {
+		return nil
+	} else if err != sql.ErrNoRows {
+		return err
+	}
+
+	hash, err := hasher.Hash(cfg.AdminPassword)
+	if err != nil {
+		return err
+	}
+
+	email := cfg.AdminEmail
+	if email == "" {
+		email = username + "@localhost"
+	}
+
+	admin := &models.User{
+		Username:     username,
+		Email:        email,
+		Role:         auth.RoleAdmin,
+		PasswordHash: hash,
+	}
+	if err := users.Create(ctx, admin); err != nil {
+		return err
 	}
+	slog.Info("Created bootstrap admin", "username", username)
+	return nil
 }
@@ -0,0 +1,191 @@
+package auth
+
+import (
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Password hashes are stored as
+//
+//	$pbkdf2-sha256$i=<iterations>$<salt>$<key>
+//
+// with salt and key in unpadded base64. Keeping the parameters in the hash
+// lets us raise the work factor later and rehash users as they log in.
+const hashScheme = "pbkdf2-sha256"
+
+const (
+	// DefaultIterations follows the OWASP recommendation for PBKDF2-HMAC-SHA256
+	DefaultIterations = 600_000
+
+	saltLength = 16
+	keyLength  = 32
+)
+
+// ErrInvalidHash is returned when a stored hash cannot be parsed
+var ErrInvalidHash = errors.New("invalid password hash format")
+
+// PasswordHasher derives and verifies password hashes
+type PasswordHasher struct {
+	Iterations int
+}
+
+// NewPasswordHasher creates a hasher using the given iteration count, falling
+// back to DefaultIterations when it is not positive
+func NewPasswordHasher(iterations int) *PasswordHasher {
+	if iterations <= 0 {
+		iterations = DefaultIterations
+	}
+	return &PasswordHasher{Iterations: iterations}
+}
+
+// Hash derives a salted hash for the given password
+func (h *PasswordHasher) Hash(password string) (string, error) {
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key, err := pbkdf2.Key(sha256.New, password, salt, h.Iterations, keyLength)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("$%s$i=%d$%s$%s",
+		hashScheme,
+		h.Iterations,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Verify reports whether the password matches the stored hash
+func (h *PasswordHasher) Verify(encoded, password string) (bool, error) {
+	iterations, salt, key, err := decodeHash(encoded)
+	if err != nil {
+		return false, err
+	}
+
+	candidate, err := pbkdf2.Key(sha256.New, password, salt, iterations, len(key))
+	if err != nil {
+		return false, err
+	}
+	return subtle.ConstantTimeCompare(candidate, key) == 1, nil
+}
+
+// NeedsRehash reports whether the stored hash was produced with weaker
+// parameters than the hasher currently uses
+func (h *PasswordHasher) NeedsRehash(encoded string) bool {
+	iterations, _, key, err := decodeHash(encoded)
+	if err != nil {
+		return true
+	}
+	return iterations < h.Iterations || len(key) < keyLength
+}
+
+// decodeHash splits a stored hash into its parameters
+func decodeHash(encoded string) (int, []byte, []byte, error) {
+	// Leading "$" produces an empty first element
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 5 || parts[0] != "" || parts[1] != hashScheme {
+		return 0, nil, nil, ErrInvalidHash
+	}
+
+	iterStr, ok := strings.CutPrefix(parts[2], "i=")
+	if !ok {
+		return 0, nil, nil, ErrInvalidHash
+	}
+	iterations, err := strconv.Atoi(iterStr)
+	if err != nil || iterations <= 0 {
+		return 0, nil, nil, ErrInvalidHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return 0, nil, nil, ErrInvalidHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil || len(key) == 0 {
+		return 0, nil, nil, ErrInvalidHash
+	}
+	return iterations, salt, key, nil
+}
+
+// PasswordPolicy describes the strength requirements for new passwords
+type PasswordPolicy struct {
+	MinLength     int
+	MaxLength     int
+	RequireUpper  bool
+	RequireLower  bool
+	RequireDigit  bool
+	RequireSymbol bool
+}
+
+// DefaultPasswordPolicy returns the policy used when nothing is configured
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength: 12,
+		MaxLength: 256,
+	}
+}
+
+// PolicyError lists every requirement a password failed to meet
+type PolicyError struct {
+	Violations []string
+}
+
+func (e *PolicyError) Error() string {
+	return "password " + strings.Join(e.Violations, "; ")
+}
+
+// Check validates a password against the policy
+func (p PasswordPolicy) Check(password string) error {
+	var violations []string
+
+	length := utf8.RuneCountInString(password)
+	if p.MinLength > 0 && length < p.MinLength {
+		violations = append(violations, fmt.Sprintf("must be at least %d characters", p.MinLength))
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		violations = append(violations, fmt.Sprintf("must be at most %d characters", p.MaxLength))
+	}
+
+	var hasUpper, hasLower, hasDigit, hasSymbol bool
+	for _, c := range password {
+		switch {
+		case unicode.IsUpper(c):
+			hasUpper = true
+		case unicode.IsLower(c):
+			hasLower = true
+		case unicode.IsDigit(c):
+			hasDigit = true
+		case unicode.IsPunct(c) || unicode.IsSymbol(c):
+			hasSymbol = true
+		}
+	}
+	if p.RequireUpper && !hasUpper {
+		violations = append(violations, "must contain an uppercase letter")
+	}
+	if p.RequireLower && !hasLower {
+		violations = append(violations, "must contain a lowercase letter")
+	}
+	if p.RequireDigit && !hasDigit {
+		violations = append(violations, "must contain a digit")
+	}
+	if p.RequireSymbol && !hasSymbol {
+		violations = append(violations, "must contain a symbol")
+	}
+
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testIterations keeps hashing fast; the work factor does not change the logic
+const testIterations = 1000
+
+func TestHashVerify(t *testing.T) {
+	h := NewPasswordHasher(testIterations)
+	encoded, err := h.Hash("correct horse battery")
+	if err != nil {
+		t.Fatalf("Hash: %v", err)
+	}
+	if !strings.HasPrefix(encoded, "$pbkdf2-sha256$i=1000$") {
+		t.Fatalf("Hash = %q, want pbkdf2-sha256 with 1000 iterations", encoded)
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		want     bool
+	}{
+		{"same password", "correct horse battery", true},
+		{"different password", "correct horse battery!", false},
+		{"empty password", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := h.Verify(encoded, tt.password)
+			if err != nil {
+				t.Fatalf("Verify: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Verify = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHashSaltsEachPassword(t *testing.T) {
+	h := NewPasswordHasher(testIterations)
+	a, _ := h.Hash("same password")
+	b, _ := h.Hash("same password")
+	if a == b {
+		t.Errorf("two hashes of the same password are equal: %q", a)
+	}
+}
+
+func TestVerifyUsesStoredIterations(t *testing.T) {
+	encoded, _ := NewPasswordHasher(testIterations).Hash("a password")
+	ok, err := NewPasswordHasher(2*testIterations).Verify(encoded, "a password")
+	if err != nil || !ok {
+		t.Errorf("Verify with a stronger hasher = %v, %v; want true, nil", ok, err)
+	}
+}
+
+func TestVerifyInvalidHash(t *testing.T) {
+	h := NewPasswordHasher(testIterations)
+	tests := []struct {
+		name    string
+		encoded string
+	}{
+		{"empty", ""},
+		{"plaintext", "secret"},
+		{"other scheme", "$bcrypt$i=1000$c2FsdA$a2V5"},
+		{"missing iterations prefix", "$pbkdf2-sha256$1000$c2FsdA$a2V5"},
+		{"zero iterations", "$pbkdf2-sha256$i=0$c2FsdA$a2V5"},
+		{"bad salt", "$pbkdf2-sha256$i=1000$!!$a2V5"},
+		{"empty key", "$pbkdf2-sha256$i=1000$c2FsdA$"},
+		{"extra part", "$pbkdf2-sha256$i=1000$c2FsdA$a2V5$x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := h.Verify(tt.encoded, "secret")
+			if ok || !errors.Is(err, ErrInvalidHash) {
+				t.Errorf("Verify = %v, %v; want false, ErrInvalidHash", ok, err)
+			}
+		})
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	weak, _ := NewPasswordHasher(testIterations).Hash("a password")
+	h := NewPasswordHasher(2 * testIterations)
+	strong, _ := h.Hash("a password")
+
+	tests := []struct {
+		name    string
+		encoded string
+		want    bool
+	}{
+		{"current parameters", strong, false},
+		{"fewer iterations", weak, true},
+		{"short key", "$pbkdf2-sha256$i=2000$c2FsdA$a2V5", true},
+		{"unparseable", "secret", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := h.NeedsRehash(tt.encoded); got != tt.want {
+				t.Errorf("NeedsRehash = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPasswordHasherDefault(t *testing.T) {
+	if got := NewPasswordHasher(0).Iterations; got != DefaultIterations {
+		t.Errorf("Iterations = %d, want %d", got, DefaultIterations)
+	}
+}
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	strict := PasswordPolicy{
+		MinLength:     8,
+		MaxLength:     12,
+		RequireUpper:  true,
+		RequireLower:  true,
+		RequireDigit:  true,
+		RequireSymbol: true,
+	}
+	tests := []struct {
+		name     string
+		policy   PasswordPolicy
+		password string
+		want     []string
+	}{
+		{"default accepts long passphrase", DefaultPasswordPolicy(), "plain old passphrase", nil},
+		{"default rejects short", DefaultPasswordPolicy(), "short", []string{"must be at least 12 characters"}},
+		{"length counts runes", PasswordPolicy{MinLength: 4}, "ßßßß", nil},
+		{"strict accepts all classes", strict, "Abcdef1!", nil},
+		{"strict rejects too long", strict, "Abcdef1!Abcdef1!", []string{"must be at most 12 characters"}},
+		{"strict lists every violation", strict, "abc", []string{
+			"must be at least 8 characters",
+			"must contain an uppercase letter",
+			"must contain a digit",
+			"must contain a symbol",
+		}},
+		{"no requirements", PasswordPolicy{}, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Check(tt.password)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("Check = %v, want nil", err)
+				}
+				return
+			}
+			var policyErr *PolicyError
+			if !errors.As(err, &policyErr) {
+				t.Fatalf("Check = %v, want *PolicyError", err)
+			}
+			if !reflect.DeepEqual(policyErr.Violations, tt.want) {
+				t.Errorf("Violations = %q, want %q", policyErr.Violations, tt.want)
+			}
+		})
+	}
+}
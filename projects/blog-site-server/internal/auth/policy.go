@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"blog-app/internal/models"
+)
+
+// Roles understood by the authorization policy, from most to least
+// privileged. The first admin is created at startup from the
+// BOOTSTRAP_ADMIN_* settings or by updating users.role directly. The
+// validate tag of models.User.Role must list the same values.
+const (
+	RoleAdmin  = "admin"
+	RoleEditor = "editor"
+	RoleAuthor = "author"
+	RoleReader = "reader"
+)
+
+// DefaultRole is assigned to users who sign up without one
+const DefaultRole = RoleReader
+
+// IsAdmin reports whether the user has the admin role
+func IsAdmin(user *models.User) bool {
+	return user != nil && user.Role == RoleAdmin
+}
+
+// canModerate reports whether the user may edit anyone's content
+func canModerate(user *models.User) bool {
+	return user != nil && (user.Role == RoleAdmin || user.Role == RoleEditor)
+}
+
+// CanCreateBlog reports whether the user may publish blog posts
+func CanCreateBlog(user *models.User) bool {
+	return canModerate(user) || (user != nil && user.Role == RoleAuthor)
+}
+
+// CanPublishAs reports whether the user may create a post attributed to authorID
+func CanPublishAs(user *models.User, authorID int64) bool {
+	return CanCreateBlog(user) && (user.ID == authorID || canModerate(user))
+}
+
+// CanModifyBlog reports whether the user may edit or delete the blog post.
+// Authors may only touch their own posts; editors and admins any post.
+func CanModifyBlog(user *models.User, blog *models.Blog) bool {
+	if canModerate(user) {
+		return true
+	}
+	return user != nil && user.Role == RoleAuthor && blog.AuthorID == user.ID
+}
+
+// CanModifyComment reports whether the user may edit or delete the comment.
+// Commenters may only touch their own comments; editors and admins any.
+func CanModifyComment(user *models.User, comment *models.Comment) bool {
+	return canModerate(user) || (user != nil && comment.UserID == user.ID)
+}
+
+// CanModifyUser reports whether the user may edit or delete the target account
+func CanModifyUser(user *models.User, targetID int64) bool {
+	return IsAdmin(user) || (user != nil && user.ID == targetID)
+}
+
+// CanChangeRoleOrStatus reports whether the user may change another
+// account's Role or IsActive. Only admins may do so.
+func CanChangeRoleOrStatus(user *models.User) bool {
+	return IsAdmin(user)
+}
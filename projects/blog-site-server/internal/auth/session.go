@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"blog-app/internal/models"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// tokenBytes is the amount of randomness in a session token
+const tokenBytes = 32
+
+// NewSessionToken returns a random bearer token and the hash to store for it
+func NewSessionToken() (token string, tokenHash string, err error) {
+	buf := make([]byte, tokenBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(buf)
+	return token, HashToken(token), nil
+}
+
+// HashToken returns the value stored in the sessions table for a token
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+type contextKey int
+
+const (
+	userKey contextKey = iota
+	sessionKey
+)
+
+// WithUser returns a copy of ctx carrying the authenticated user and session
+func WithUser(ctx context.Context, user *models.User, session *models.Session) context.Context {
+	ctx = context.WithValue(ctx, userKey, user)
+	return context.WithValue(ctx, sessionKey, session)
+}
+
+// UserFromContext returns the authenticated user, or nil for anonymous requests
+func UserFromContext(ctx context.Context) *models.User {
+	user, _ := ctx.Value(userKey).(*models.User)
+	return user
+}
+
+// SessionFromContext returns the session the request authenticated with
+func SessionFromContext(ctx context.Context) *models.Session {
+	session, _ := ctx.Value(sessionKey).(*models.Session)
+	return session
+}
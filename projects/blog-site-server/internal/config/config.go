@@ -0,0 +1,330 @@
+package config
+
+import (
+	"blog-app/internal/auth"
+	"blog-app/internal/ratelimit"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Config is the complete server configuration. Load fills it from, in
+// increasing precedence, the defaults, an optional JSON config file,
+// environment variables and command-line flags.
+type Config struct {
+	Storage   string
+	Server    Server
+	CORS      CORS
+	Database  Database
+	Auth      Auth
+	Comments  Comments
+	Bootstrap Bootstrap
+	RateLimit RateLimit
+	Metrics   Metrics
+	Log       Log
+
+	// File is the config file that was read, if any
+	File string
+	// PrintConfig asks for the effective configuration to be printed
+	PrintConfig bool
+	// Args holds the command-line arguments left after the flags
+	Args []string
+}
+
+// Server configures the HTTP listener. On shutdown readiness fails for
+// ShutdownDelay, giving load balancers time to stop routing new requests,
+// before in-flight requests get ShutdownGracePeriod to finish.
+type Server struct {
+	Port                int
+	ReadHeaderTimeout   time.Duration
+	ReadTimeout         time.Duration
+	WriteTimeout        time.Duration
+	IdleTimeout         time.Duration
+	MaxHeaderBytes      int
+	MaxBodyBytes        int
+	ShutdownDelay       time.Duration
+	ShutdownGracePeriod time.Duration
+}
+
+// CORS configures cross-origin access from browsers. No origins are
+// allowed unless listed; "*" allows any origin but not with credentials.
+type CORS struct {
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// Database configures the Postgres connection pool
+type Database struct {
+	URL             string
+	ConnectTimeout  time.Duration
+	AutoMigrate     bool
+	QueryTimeout    time.Duration
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
+
+// RedactedURL returns the connection string with its password hidden, for
+// logging
+func (d Database) RedactedURL() string {
+	return redactURL(d.URL)
+}
+
+// Auth configures sessions and password handling
+type Auth struct {
+	SessionTTL     time.Duration
+	HashIterations int
+	Password       auth.PasswordPolicy
+}
+
+// Comments configures comment threads
+type Comments struct {
+	MaxTreeDepth int
+}
+
+// Bootstrap names the admin account created on first start
+type Bootstrap struct {
+	AdminUsername string
+	AdminPassword string
+	AdminEmail    string
+}
+
+// RateLimit configures per-client request limits. Every route belongs to a
+// group whose limit applies to each authenticated user, or to each client
+// IP for anonymous requests; a limit of zero requests disables the group.
+type RateLimit struct {
+	Read   ratelimit.Limit
+	Write  ratelimit.Limit
+	Auth   ratelimit.Limit
+	Signup ratelimit.Limit
+	// MaxClients bounds the number of clients tracked per group
+	MaxClients int
+	// TrustedProxies lists the addresses or CIDR ranges whose
+	// X-Forwarded-For header is believed
+	TrustedProxies []string
+}
+
+// Groups maps route group names to their limits
+func (r RateLimit) Groups() map[string]ratelimit.Limit {
+	return map[string]ratelimit.Limit{
+		"read":   r.Read,
+		"write":  r.Write,
+		"auth":   r.Auth,
+		"signup": r.Signup,
+	}
+}
+
+// TrustedProxyPrefixes parses TrustedProxies; single addresses become
+// one-address prefixes
+func (r RateLimit) TrustedProxyPrefixes() ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, proxy := range r.TrustedProxies {
+		if !strings.Contains(proxy, "/") {
+			addr, err := netip.ParseAddr(proxy)
+			if err != nil {
+				return nil, fmt.Errorf("invalid address %q", proxy)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR range %q", proxy)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// Metrics configures the Prometheus endpoint. With an empty Address it is
+// served on the main port to admins only; otherwise on its own listener
+// without authentication.
+type Metrics struct {
+	Address string
+}
+
+// Log configures the process logger
+type Log struct {
+	Format string
+	Level  slog.Level
+}
+
+// Default returns the configuration used when nothing is overridden
+func Default() *Config {
+	return &Config{
+		Storage: "postgres",
+		Server: Server{
+			Port:                8080,
+			ReadHeaderTimeout:   5 * time.Second,
+			ReadTimeout:         15 * time.Second,
+			WriteTimeout:        30 * time.Second,
+			IdleTimeout:         120 * time.Second,
+			MaxHeaderBytes:      1 << 20,
+			MaxBodyBytes:        1 << 20,
+			ShutdownDelay:       5 * time.Second,
+			ShutdownGracePeriod: 30 * time.Second,
+		},
+		CORS: CORS{
+			AllowedMethods: []string{"GET", "POST", "PUT", "PATCH", "DELETE"},
+			AllowedHeaders: []string{"Authorization", "Content-Type", "If-Match", "X-Request-ID"},
+			ExposedHeaders: []string{"ETag", "X-Request-ID", "Retry-After", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset"},
+			MaxAge:         10 * time.Minute,
+		},
+		Database: Database{
+			ConnectTimeout:  30 * time.Second,
+			QueryTimeout:    5 * time.Second,
+			MaxOpenConns:    25,
+			MaxIdleConns:    25,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+		},
+		Auth: Auth{
+			SessionTTL:     24 * time.Hour,
+			HashIterations: auth.DefaultIterations,
+			Password:       auth.DefaultPasswordPolicy(),
+		},
+		Comments: Comments{
+			MaxTreeDepth: 5,
+		},
+		RateLimit: RateLimit{
+			Read:       ratelimit.Limit{Requests: 300, Period: time.Minute},
+			Write:      ratelimit.Limit{Requests: 60, Period: time.Minute},
+			Auth:       ratelimit.Limit{Requests: 10, Period: time.Minute},
+			Signup:     ratelimit.Limit{Requests: 20, Period: time.Hour},
+			MaxClients: 100000,
+		},
+		Log: Log{
+			Format: "text",
+			Level:  slog.LevelInfo,
+		},
+	}
+}
+
+// Load builds the configuration for a command line (without the program
+// name). Every malformed or invalid setting is reported in one error.
+// flag.ErrHelp is returned as is when -h was requested.
+func Load(args []string, lookupEnv func(string) (string, bool)) (*Config, error) {
+	cfg := Default()
+	settings := cfg.settings()
+
+	// Flags are parsed first to find the config file, but applied last
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	fs.StringVar(&cfg.File, "config", "", "path to a JSON config file (env CONFIG_FILE)")
+	fs.BoolVar(&cfg.PrintConfig, "print-config", false, "print the effective configuration and exit")
+	fromFlags := make(map[string]string)
+	for _, s := range settings {
+		fs.Var(&flagValue{setting: s, values: fromFlags}, s.key, s.usage())
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	cfg.Args = fs.Args()
+
+	var errs []error
+	if cfg.File == "" {
+		cfg.File, _ = lookupEnv("CONFIG_FILE")
+	}
+	if cfg.File != "" {
+		errs = append(errs, loadFile(cfg.File, settings)...)
+	}
+	for _, s := range settings {
+		if value, ok := lookupEnv(s.env); ok && value != "" {
+			if err := s.set(value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", s.env, err))
+			}
+		}
+	}
+	for _, s := range settings {
+		if value, ok := fromFlags[s.key]; ok {
+			if err := s.set(value); err != nil {
+				errs = append(errs, fmt.Errorf("-%s: %w", s.key, err))
+			}
+		}
+	}
+
+	errs = append(errs, cfg.validate()...)
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return cfg, nil
+}
+
+// validate checks the settings against each other and their allowed ranges
+func (c *Config) validate() []error {
+	var errs []error
+	check := func(ok bool, format string, args ...any) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Storage == "postgres" || c.Storage == "memory",
+		"storage: unknown backend %q, expected postgres or memory", c.Storage)
+
+	check(c.Server.Port > 0 && c.Server.Port <= 65535, "server.port: must be between 1 and 65535")
+	check(c.Server.ReadHeaderTimeout > 0, "server.read-header-timeout: must be positive")
+	check(c.Server.ReadTimeout > 0, "server.read-timeout: must be positive")
+	check(c.Server.WriteTimeout > 0, "server.write-timeout: must be positive")
+	check(c.Server.IdleTimeout > 0, "server.idle-timeout: must be positive")
+	check(c.Server.MaxHeaderBytes > 0, "server.max-header-bytes: must be positive")
+	check(c.Server.MaxBodyBytes > 0, "server.max-body-bytes: must be positive")
+	check(c.Server.ShutdownDelay >= 0, "server.shutdown-delay: must not be negative")
+	check(c.Server.ShutdownGracePeriod > 0, "server.shutdown-grace-period: must be positive")
+
+	check(!c.CORS.AllowCredentials || !slices.Contains(c.CORS.AllowedOrigins, "*"),
+		"cors.allow-credentials: cannot be combined with the \"*\" origin")
+	check(c.CORS.MaxAge >= 0, "cors.max-age: must not be negative")
+
+	if c.Storage == "postgres" {
+		check(c.Database.URL != "", "database.url: required when storage is postgres")
+	}
+	check(c.Database.ConnectTimeout > 0, "database.connect-timeout: must be positive")
+	check(c.Database.QueryTimeout >= 0, "database.query-timeout: must not be negative")
+	check(c.Database.MaxOpenConns >= 0, "database.max-open-conns: must not be negative")
+	check(c.Database.MaxIdleConns >= 0, "database.max-idle-conns: must not be negative")
+	check(c.Database.ConnMaxLifetime >= 0, "database.conn-max-lifetime: must not be negative")
+	check(c.Database.ConnMaxIdleTime >= 0, "database.conn-max-idle-time: must not be negative")
+
+	check(c.Auth.SessionTTL > 0, "auth.session-ttl: must be positive")
+	check(c.Auth.HashIterations > 0, "auth.hash-iterations: must be positive")
+	check(c.Auth.Password.MinLength >= 0, "auth.password.min-length: must not be negative")
+	check(c.Auth.Password.MaxLength == 0 || c.Auth.Password.MaxLength >= c.Auth.Password.MinLength,
+		"auth.password.max-length: must be 0 or at least auth.password.min-length")
+
+	check(c.Comments.MaxTreeDepth >= 0, "comments.max-tree-depth: must not be negative")
+
+	groups := c.RateLimit.Groups()
+	for _, name := range []string{"read", "write", "auth", "signup"} {
+		limit := groups[name]
+		check(limit.Requests >= 0, "ratelimit.%s.requests: must not be negative", name)
+		check(limit.Requests == 0 || limit.Period > 0, "ratelimit.%s.period: must be positive", name)
+	}
+	check(c.RateLimit.MaxClients > 0, "ratelimit.max-clients: must be positive")
+	if _, err := c.RateLimit.TrustedProxyPrefixes(); err != nil {
+		check(false, "ratelimit.trusted-proxies: %v", err)
+	}
+
+	if c.Metrics.Address != "" {
+		_, _, err := net.SplitHostPort(c.Metrics.Address)
+		check(err == nil, "metrics.address: expected host:port, got %q", c.Metrics.Address)
+	}
+
+	check(c.Log.Format == "text" || c.Log.Format == "json",
+		"log.format: unknown format %q, expected text or json", c.Log.Format)
+
+	if c.Bootstrap.AdminUsername != "" {
+		check(c.Bootstrap.AdminPassword != "", "bootstrap.admin-password: required with bootstrap.admin-username")
+	}
+	return errs
+}
@@ -0,0 +1,321 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// redacted replaces secret values in printed configuration
+const redacted = "[REDACTED]"
+
+// setting binds one configuration value to its names in every source. The
+// key is both the flag name and the dotted path in the config file.
+type setting struct {
+	key    string
+	env    string
+	help   string
+	target any
+	// redact hides secrets when the configuration is printed
+	redact func(string) string
+}
+
+// settings lists every configurable value of c
+func (c *Config) settings() []*setting {
+	return []*setting{
+		{key: "storage", env: "STORAGE", help: "storage backend, postgres or memory", target: &c.Storage},
+
+		{key: "server.port", env: "PORT", help: "HTTP listen port", target: &c.Server.Port},
+		{key: "server.read-header-timeout", env: "SERVER_READ_HEADER_TIMEOUT", help: "time allowed to read request headers", target: &c.Server.ReadHeaderTimeout},
+		{key: "server.read-timeout", env: "SERVER_READ_TIMEOUT", help: "time allowed to read a whole request", target: &c.Server.ReadTimeout},
+		{key: "server.write-timeout", env: "SERVER_WRITE_TIMEOUT", help: "time allowed to write a response", target: &c.Server.WriteTimeout},
+		{key: "server.idle-timeout", env: "SERVER_IDLE_TIMEOUT", help: "keep-alive idle timeout", target: &c.Server.IdleTimeout},
+		{key: "server.max-header-bytes", env: "SERVER_MAX_HEADER_BYTES", help: "maximum size of request headers", target: &c.Server.MaxHeaderBytes},
+		{key: "server.max-body-bytes", env: "SERVER_MAX_BODY_BYTES", help: "maximum size of request bodies", target: &c.Server.MaxBodyBytes},
+		{key: "server.shutdown-delay", env: "SHUTDOWN_DELAY", help: "time readiness fails before draining starts on shutdown", target: &c.Server.ShutdownDelay},
+		{key: "server.shutdown-grace-period", env: "SHUTDOWN_GRACE_PERIOD", help: "time allowed for in-flight requests on shutdown", target: &c.Server.ShutdownGracePeriod},
+
+		{key: "cors.allowed-origins", env: "CORS_ALLOWED_ORIGINS", help: "comma-separated origins allowed to call the API, or *", target: &c.CORS.AllowedOrigins},
+		{key: "cors.allowed-methods", env: "CORS_ALLOWED_METHODS", help: "comma-separated methods allowed in cross-origin requests", target: &c.CORS.AllowedMethods},
+		{key: "cors.allowed-headers", env: "CORS_ALLOWED_HEADERS", help: "comma-separated request headers allowed in cross-origin requests", target: &c.CORS.AllowedHeaders},
+		{key: "cors.exposed-headers", env: "CORS_EXPOSED_HEADERS", help: "comma-separated response headers readable by scripts", target: &c.CORS.ExposedHeaders},
+		{key: "cors.allow-credentials", env: "CORS_ALLOW_CREDENTIALS", help: "allow cookies and authorization headers cross-origin", target: &c.CORS.AllowCredentials},
+		{key: "cors.max-age", env: "CORS_MAX_AGE", help: "how long browsers may cache preflight results", target: &c.CORS.MaxAge},
+
+		{key: "database.url", env: "DATABASE_URL", help: "Postgres connection string", target: &c.Database.URL, redact: redactURL},
+		{key: "database.connect-timeout", env: "DB_CONNECT_TIMEOUT", help: "how long to keep retrying the initial connection", target: &c.Database.ConnectTimeout},
+		{key: "database.auto-migrate", env: "AUTO_MIGRATE", help: "apply pending migrations on startup", target: &c.Database.AutoMigrate},
+		{key: "database.query-timeout", env: "QUERY_TIMEOUT", help: "deadline for each repository call, 0 for none", target: &c.Database.QueryTimeout},
+		{key: "database.max-open-conns", env: "DB_MAX_OPEN_CONNS", help: "maximum open connections, 0 for unlimited", target: &c.Database.MaxOpenConns},
+		{key: "database.max-idle-conns", env: "DB_MAX_IDLE_CONNS", help: "maximum idle connections kept in the pool", target: &c.Database.MaxIdleConns},
+		{key: "database.conn-max-lifetime", env: "DB_CONN_MAX_LIFETIME", help: "maximum age of a connection, 0 for unlimited", target: &c.Database.ConnMaxLifetime},
+		{key: "database.conn-max-idle-time", env: "DB_CONN_MAX_IDLE_TIME", help: "maximum idle time of a connection, 0 for unlimited", target: &c.Database.ConnMaxIdleTime},
+
+		{key: "auth.session-ttl", env: "SESSION_TTL", help: "lifetime of login sessions", target: &c.Auth.SessionTTL},
+		{key: "auth.hash-iterations", env: "PASSWORD_HASH_ITERATIONS", help: "PBKDF2 iterations for new password hashes", target: &c.Auth.HashIterations},
+		{key: "auth.password.min-length", env: "PASSWORD_MIN_LENGTH", help: "minimum password length", target: &c.Auth.Password.MinLength},
+		{key: "auth.password.max-length", env: "PASSWORD_MAX_LENGTH", help: "maximum password length, 0 for unlimited", target: &c.Auth.Password.MaxLength},
+		{key: "auth.password.require-upper", env: "PASSWORD_REQUIRE_UPPER", help: "require an upper-case letter", target: &c.Auth.Password.RequireUpper},
+		{key: "auth.password.require-lower", env: "PASSWORD_REQUIRE_LOWER", help: "require a lower-case letter", target: &c.Auth.Password.RequireLower},
+		{key: "auth.password.require-digit", env: "PASSWORD_REQUIRE_DIGIT", help: "require a digit", target: &c.Auth.Password.RequireDigit},
+		{key: "auth.password.require-symbol", env: "PASSWORD_REQUIRE_SYMBOL", help: "require a symbol", target: &c.Auth.Password.RequireSymbol},
+
+		{key: "comments.max-tree-depth", env: "COMMENT_TREE_MAX_DEPTH", help: "reply levels returned with a comment tree", target: &c.Comments.MaxTreeDepth},
+
+		{key: "bootstrap.admin-username", env: "BOOTSTRAP_ADMIN_USERNAME", help: "admin account created on startup if missing", target: &c.Bootstrap.AdminUsername},
+		{key: "bootstrap.admin-password", env: "BOOTSTRAP_ADMIN_PASSWORD", help: "password of the bootstrap admin", target: &c.Bootstrap.AdminPassword, redact: redactAll},
+		{key: "bootstrap.admin-email", env: "BOOTSTRAP_ADMIN_EMAIL", help: "email of the bootstrap admin", target: &c.Bootstrap.AdminEmail},
+
+		{key: "ratelimit.read.requests", env: "RATE_LIMIT_READ_REQUESTS", help: "read requests allowed per period and client, 0 for unlimited", target: &c.RateLimit.Read.Requests},
+		{key: "ratelimit.read.period", env: "RATE_LIMIT_READ_PERIOD", help: "period of the read limit", target: &c.RateLimit.Read.Period},
+		{key: "ratelimit.write.requests", env: "RATE_LIMIT_WRITE_REQUESTS", help: "write requests allowed per period and client, 0 for unlimited", target: &c.RateLimit.Write.Requests},
+		{key: "ratelimit.write.period", env: "RATE_LIMIT_WRITE_PERIOD", help: "period of the write limit", target: &c.RateLimit.Write.Period},
+		{key: "ratelimit.auth.requests", env: "RATE_LIMIT_AUTH_REQUESTS", help: "login attempts allowed per period and client, 0 for unlimited", target: &c.RateLimit.Auth.Requests},
+		{key: "ratelimit.auth.period", env: "RATE_LIMIT_AUTH_PERIOD", help: "period of the login limit", target: &c.RateLimit.Auth.Period},
+		{key: "ratelimit.signup.requests", env: "RATE_LIMIT_SIGNUP_REQUESTS", help: "sign-ups allowed per period and client, 0 for unlimited", target: &c.RateLimit.Signup.Requests},
+		{key: "ratelimit.signup.period", env: "RATE_LIMIT_SIGNUP_PERIOD", help: "period of the sign-up limit", target: &c.RateLimit.Signup.Period},
+		{key: "ratelimit.max-clients", env: "RATE_LIMIT_MAX_CLIENTS", help: "clients tracked per route group before the idlest are evicted", target: &c.RateLimit.MaxClients},
+		{key: "ratelimit.trusted-proxies", env: "TRUSTED_PROXIES", help: "comma-separated proxy addresses or CIDR ranges whose X-Forwarded-For is trusted", target: &c.RateLimit.TrustedProxies},
+
+		{key: "log.format", env: "LOG_FORMAT", help: "log output format, text or json", target: &c.Log.Format},
+		{key: "log.level", env: "LOG_LEVEL", help: "minimum log level: debug, info, warn or error", target: &c.Log.Level},
+
+		{key: "metrics.address", env: "METRICS_ADDR", help: "separate host:port for /metrics; empty serves it to admins on the main port", target: &c.Metrics.Address},
+	}
+}
+
+// usage is the flag help text
+func (s *setting) usage() string {
+	return fmt.Sprintf("%s (env %s)", s.help, s.env)
+}
+
+// set parses a raw value into the target
+func (s *setting) set(raw string) error {
+	switch target := s.target.(type) {
+	case *string:
+		*target = raw
+	case *int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		*target = n
+	case *bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		*target = b
+	case *time.Duration:
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", raw)
+		}
+		*target = d
+	case *slog.Level:
+		if err := target.UnmarshalText([]byte(raw)); err != nil {
+			return fmt.Errorf("invalid log level %q", raw)
+		}
+	case *[]string:
+		// Lists are comma-separated; an empty value clears the list
+		*target = nil
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				*target = append(*target, item)
+			}
+		}
+	default:
+		panic("config: unsupported setting type for " + s.key)
+	}
+	return nil
+}
+
+// value returns the target as it is printed, with secrets redacted
+func (s *setting) value() any {
+	switch target := s.target.(type) {
+	case *string:
+		if s.redact != nil && *target != "" {
+			return s.redact(*target)
+		}
+		return *target
+	case *int:
+		return *target
+	case *bool:
+		return *target
+	case *time.Duration:
+		return target.String()
+	case *slog.Level:
+		return target.String()
+	case *[]string:
+		return append([]string{}, *target...)
+	}
+	return nil
+}
+
+// redactAll hides the whole value
+func redactAll(string) string {
+	return redacted
+}
+
+// redactURL hides the password of a connection URL, or the whole value
+// when it is not a URL
+func redactURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" {
+		return redacted
+	}
+	if query := u.Query(); query.Has("password") {
+		query.Set("password", "xxxxx")
+		u.RawQuery = query.Encode()
+	}
+	return u.Redacted()
+}
+
+// flagValue records a flag for one setting so it can be applied after the
+// file and the environment
+type flagValue struct {
+	setting *setting
+	values  map[string]string
+}
+
+func (f *flagValue) String() string {
+	if f.setting == nil {
+		return ""
+	}
+	if list, ok := f.setting.target.(*[]string); ok {
+		return strings.Join(*list, ",")
+	}
+	return fmt.Sprint(f.setting.value())
+}
+
+func (f *flagValue) Set(raw string) error {
+	f.values[f.setting.key] = raw
+	return nil
+}
+
+// IsBoolFlag lets boolean settings be given as a bare -flag
+func (f *flagValue) IsBoolFlag() bool {
+	_, ok := f.setting.target.(*bool)
+	return ok
+}
+
+// loadFile applies a JSON config file whose nested objects mirror the
+// dotted setting keys, e.g. {"database": {"max-open-conns": 10}}
+func loadFile(path string, settings []*setting) []error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return []error{fmt.Errorf("config file: %w", err)}
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var doc map[string]any
+	if err := decoder.Decode(&doc); err != nil {
+		return []error{fmt.Errorf("config file %s: %w", path, err)}
+	}
+
+	byKey := make(map[string]*setting, len(settings))
+	for _, s := range settings {
+		byKey[s.key] = s
+	}
+
+	values := make(map[string]any)
+	flatten("", doc, values)
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	for _, key := range keys {
+		s, ok := byKey[key]
+		if !ok {
+			errs = append(errs, fmt.Errorf("config file %s: unknown setting %q", path, key))
+			continue
+		}
+		var raw string
+		switch value := values[key].(type) {
+		case string:
+			raw = value
+		case json.Number:
+			raw = value.String()
+		case bool:
+			raw = strconv.FormatBool(value)
+		case []any:
+			items, ok := stringList(value)
+			if !ok {
+				errs = append(errs, fmt.Errorf("config file %s: %s: expected a list of strings", path, key))
+				continue
+			}
+			raw = strings.Join(items, ",")
+		default:
+			errs = append(errs, fmt.Errorf("config file %s: %s: expected a string, number, boolean or list", path, key))
+			continue
+		}
+		if err := s.set(raw); err != nil {
+			errs = append(errs, fmt.Errorf("config file %s: %s: %w", path, key, err))
+		}
+	}
+	return errs
+}
+
+// stringList converts a decoded JSON array whose items are all strings
+func stringList(values []any) ([]string, bool) {
+	items := make([]string, 0, len(values))
+	for _, value := range values {
+		item, ok := value.(string)
+		if !ok {
+			return nil, false
+		}
+		items = append(items, item)
+	}
+	return items, true
+}
+
+// flatten turns nested objects into dotted keys
+func flatten(prefix string, doc map[string]any, into map[string]any) {
+	for name, value := range doc {
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+		if nested, ok := value.(map[string]any); ok {
+			flatten(key, nested, into)
+			continue
+		}
+		into[key] = value
+	}
+}
+
+// Print writes the effective configuration as JSON in the config file
+// layout, with secrets redacted
+func (c *Config) Print(w io.Writer) error {
+	doc := make(map[string]any)
+	for _, s := range c.settings() {
+		parts := strings.Split(s.key, ".")
+		node := doc
+		for _, part := range parts[:len(parts)-1] {
+			child, ok := node[part].(map[string]any)
+			if !ok {
+				child = make(map[string]any)
+				node[part] = child
+			}
+			node = child
+		}
+		node[parts[len(parts)-1]] = s.value()
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(doc)
+}
@@ -1,52 +1,70 @@
 package db
 
 import (
+	"blog-app/internal/config"
+	"context"
 	"database/sql"
-	"log"
-	"os"
+	"fmt"
+	"log/slog"
+	"time"
 
 	_ "github.com/lib/pq"
 )
 
-// DB is the global database connection pool
-var DB *sql.DB
-
-// InitializeDB initializes the database connection
-func InitializeDB() error {
-	// Get the database connection string from environment variables
-	connStr := os.Getenv("DATABASE_URL")
-	if connStr == "" {
-		log.Fatal("DATABASE_URL environment variable is not set")
-	}
+// Delays between connection attempts start at initialRetryDelay and double
+// up to maxRetryDelay
+const (
+	initialRetryDelay = 250 * time.Millisecond
+	maxRetryDelay     = 5 * time.Second
+)
 
-	// Open the database connection
-	var err error
-	DB, err = sql.Open("postgres", connStr)
+// Open creates the connection pool described by cfg and waits for the
+// database to answer, retrying with exponential backoff for up to
+// cfg.ConnectTimeout so the server survives Postgres starting after it
+func Open(ctx context.Context, cfg config.Database) (*sql.DB, error) {
+	database, err := sql.Open("postgres", cfg.URL)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Verify the connection
-	if err := DB.Ping(); err != nil {
-		return err
-	}
+	database.SetMaxOpenConns(cfg.MaxOpenConns)
+	database.SetMaxIdleConns(cfg.MaxIdleConns)
+	database.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	database.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
 
-	log.Println("Database connection established")
-	return nil
-}
+	ctx, cancel := context.WithTimeout(ctx, cfg.ConnectTimeout)
+	defer cancel()
 
-// GetDB returns the database connection pool
-func GetDB() *sql.DB {
-	return DB
+	dsn := cfg.RedactedURL()
+	delay := initialRetryDelay
+	for attempt := 1; ; attempt++ {
+		slog.Info("Connecting to database", "dsn", dsn, "attempt", attempt)
+		err = database.PingContext(ctx)
+		if err == nil {
+			break
+		}
+		if ctx.Err() == nil {
+			slog.Warn("Database not ready", "error", err, "retry_in", delay.String())
+			select {
+			case <-time.After(delay):
+				delay = min(2*delay, maxRetryDelay)
+				continue
+			case <-ctx.Done():
+			}
+		}
+		database.Close()
+		return nil, fmt.Errorf("database not reachable after %d attempt(s) within %s: %w", attempt, cfg.ConnectTimeout, err)
+	}
+
+	slog.Info("Database connection established")
+	return database, nil
 }
 
-// CloseDB closes the database connection
-func CloseDB() {
-	if DB != nil {
-		if err := DB.Close(); err != nil {
-			log.Println("Error closing database connection:", err)
-		} else {
-			log.Println("Database connection closed")
-		}
+// Close closes the connection pool, logging the outcome
+func Close(database *sql.DB) {
+	if err := database.Close(); err != nil {
+		slog.Error("Error closing database connection", "error", err)
+	} else {
+		slog.Info("Database connection closed")
 	}
 }
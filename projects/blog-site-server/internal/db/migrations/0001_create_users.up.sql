@@ -0,0 +1,17 @@
+CREATE TABLE users (
+    id            BIGSERIAL PRIMARY KEY,
+    username      TEXT NOT NULL,
+    full_name     TEXT NOT NULL DEFAULT '',
+    email         TEXT NOT NULL,
+    password_hash TEXT NOT NULL DEFAULT '',
+    role          TEXT NOT NULL DEFAULT 'reader',
+    bio           TEXT NOT NULL DEFAULT '',
+    avatar_url    TEXT NOT NULL DEFAULT '',
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT now(),
+    is_active     BOOLEAN NOT NULL DEFAULT TRUE,
+    CONSTRAINT users_username_key UNIQUE (username),
+    CONSTRAINT users_email_key UNIQUE (email)
+);
+
+CREATE INDEX users_created_at_idx ON users (created_at DESC, id DESC);
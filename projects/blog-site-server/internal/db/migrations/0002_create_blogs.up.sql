@@ -0,0 +1,13 @@
+CREATE TABLE blogs (
+    id          BIGSERIAL PRIMARY KEY,
+    title       TEXT NOT NULL,
+    content     TEXT NOT NULL DEFAULT '',
+    cover_image TEXT NOT NULL DEFAULT '',
+    author_id   BIGINT NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at  TIMESTAMPTZ,
+    CONSTRAINT blogs_author_id_fkey FOREIGN KEY (author_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX blogs_author_id_idx ON blogs (author_id);
+CREATE INDEX blogs_created_at_idx ON blogs (created_at DESC, id DESC);
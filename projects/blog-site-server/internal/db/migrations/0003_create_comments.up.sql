@@ -0,0 +1,13 @@
+CREATE TABLE comments (
+    id         BIGSERIAL PRIMARY KEY,
+    post_id    BIGINT NOT NULL,
+    user_id    BIGINT NOT NULL,
+    content    TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT comments_post_id_fkey FOREIGN KEY (post_id) REFERENCES blogs (id) ON DELETE CASCADE,
+    CONSTRAINT comments_user_id_fkey FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+
+CREATE INDEX comments_post_id_created_at_idx ON comments (post_id, created_at DESC, id DESC);
+CREATE INDEX comments_user_id_idx ON comments (user_id);
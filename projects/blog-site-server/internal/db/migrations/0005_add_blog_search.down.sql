@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS blogs_search_idx;
+
+ALTER TABLE blogs DROP COLUMN IF EXISTS search;
@@ -0,0 +1,6 @@
+ALTER TABLE blogs ADD COLUMN search tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+    setweight(to_tsvector('english', coalesce(content, '')), 'B')
+) STORED;
+
+CREATE INDEX blogs_search_idx ON blogs USING GIN (search);
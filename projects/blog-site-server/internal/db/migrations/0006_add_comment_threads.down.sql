@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS comments_parent_id_idx;
+
+ALTER TABLE comments DROP CONSTRAINT IF EXISTS comments_parent_fkey;
+ALTER TABLE comments DROP CONSTRAINT IF EXISTS comments_id_post_id_key;
+
+ALTER TABLE comments DROP COLUMN IF EXISTS is_deleted;
+ALTER TABLE comments DROP COLUMN IF EXISTS parent_id;
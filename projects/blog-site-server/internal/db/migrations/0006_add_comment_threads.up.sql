@@ -0,0 +1,10 @@
+ALTER TABLE comments ADD COLUMN parent_id BIGINT;
+ALTER TABLE comments ADD COLUMN is_deleted BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Replies must belong to the same post as their parent, so the foreign key
+-- covers (parent_id, post_id) rather than parent_id alone
+ALTER TABLE comments ADD CONSTRAINT comments_id_post_id_key UNIQUE (id, post_id);
+ALTER TABLE comments ADD CONSTRAINT comments_parent_fkey
+    FOREIGN KEY (parent_id, post_id) REFERENCES comments (id, post_id) ON DELETE CASCADE;
+
+CREATE INDEX comments_parent_id_idx ON comments (parent_id, created_at, id);
@@ -0,0 +1,3 @@
+ALTER TABLE comments DROP COLUMN IF EXISTS version;
+ALTER TABLE users DROP COLUMN IF EXISTS version;
+ALTER TABLE blogs DROP COLUMN IF EXISTS version;
@@ -0,0 +1,5 @@
+-- Every write bumps version; it is exposed as the ETag of the resource and
+-- checked against If-Match in the WHERE clause of conditional writes
+ALTER TABLE blogs ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE users ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE comments ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
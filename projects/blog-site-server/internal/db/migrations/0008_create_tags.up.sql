@@ -0,0 +1,19 @@
+-- Tags are identified by their normalized slug; see models.TagSlug
+CREATE TABLE tags (
+    id         BIGSERIAL PRIMARY KEY,
+    slug       TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CONSTRAINT tags_slug_key UNIQUE (slug),
+    CONSTRAINT tags_slug_check CHECK (slug <> '')
+);
+
+CREATE TABLE blog_tags (
+    blog_id BIGINT NOT NULL,
+    tag_id  BIGINT NOT NULL,
+    CONSTRAINT blog_tags_pkey PRIMARY KEY (blog_id, tag_id),
+    CONSTRAINT blog_tags_blog_id_fkey FOREIGN KEY (blog_id) REFERENCES blogs (id) ON DELETE CASCADE,
+    CONSTRAINT blog_tags_tag_id_fkey FOREIGN KEY (tag_id) REFERENCES tags (id) ON DELETE CASCADE
+);
+
+-- The primary key serves lookups by blog; this one serves lookups by tag
+CREATE INDEX blog_tags_tag_id_idx ON blog_tags (tag_id, blog_id);
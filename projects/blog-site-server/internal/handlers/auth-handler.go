@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"blog-app/internal/auth"
+	"blog-app/internal/models"
+	"blog-app/internal/repository"
+	"database/sql"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type AuthHandler struct {
+	users    repository.UserStore
+	sessions repository.SessionStore
+	hasher   *auth.PasswordHasher
+	ttl      time.Duration
+
+	// dummyHash is verified against when the username is unknown so that
+	// login timing does not reveal which usernames exist
+	dummyOnce sync.Once
+	dummyHash string
+}
+
+func NewAuthHandler(users repository.UserStore, sessions repository.SessionStore, hasher *auth.PasswordHasher, ttl time.Duration) *AuthHandler {
+	return &AuthHandler{users: users, sessions: sessions, hasher: hasher, ttl: ttl}
+}
+
+// loginRequest is the payload for POST /auth/login
+type loginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// loginResponse carries the issued bearer token
+type loginResponse struct {
+	Token     string       `json:"token"`
+	TokenType string       `json:"token_type"`
+	ExpiresAt time.Time    `json:"expires_at"`
+	User      *models.User `json:"user"`
+}
+
+// Login verifies credentials and issues a session token
+func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
+	var req loginRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+
+	user, err := h.users.GetByUsername(r.Context(), req.Username)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			h.dummyOnce.Do(func() {
+				h.dummyHash, _ = h.hasher.Hash("dummy password")
+			})
+			h.hasher.Verify(h.dummyHash, req.Password)
+			WriteError(w, r, http.StatusUnauthorized, CodeInvalidCredentials, "Invalid username or password")
+		} else {
+			WriteStoreError(w, r, err, "User", "Failed to log in")
+		}
+		return
+	}
+
+	ok, _ := h.hasher.Verify(user.PasswordHash, req.Password)
+	if !ok {
+		WriteError(w, r, http.StatusUnauthorized, CodeInvalidCredentials, "Invalid username or password")
+		return
+	}
+	if !user.IsActive {
+		WriteError(w, r, http.StatusForbidden, CodeAccountInactive, "Account is deactivated")
+		return
+	}
+
+	// Upgrade hashes produced with older parameters while we have the
+	// plaintext in hand
+	if h.hasher.NeedsRehash(user.PasswordHash) {
+		if hash, err := h.hasher.Hash(req.Password); err == nil {
+			if err := h.users.UpdatePassword(r.Context(), user.ID, hash); err != nil {
+				slog.WarnContext(r.Context(), "Failed to rehash password", "user_id", user.ID, "error", err)
+			}
+		}
+	}
+
+	token, tokenHash, err := auth.NewSessionToken()
+	if err != nil {
+		internalError(w, r, err, "Failed to log in")
+		return
+	}
+
+	session := &models.Session{
+		TokenHash: tokenHash,
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(h.ttl),
+	}
+	if err := h.sessions.Create(r.Context(), session); err != nil {
+		WriteStoreError(w, r, err, "Session", "Failed to log in")
+		return
+	}
+
+	// Opportunistically clear out sessions that have already expired
+	if err := h.sessions.DeleteExpired(r.Context()); err != nil {
+		slog.WarnContext(r.Context(), "Failed to delete expired sessions", "error", err)
+	}
+
+	user.PasswordHash = ""
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(loginResponse{
+		Token:     token,
+		TokenType: "Bearer",
+		ExpiresAt: session.ExpiresAt,
+		User:      user,
+	})
+}
+
+// Logout revokes the session the request was authenticated with
+func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	session := auth.SessionFromContext(r.Context())
+	if session == nil {
+		WriteError(w, r, http.StatusUnauthorized, CodeUnauthorized, "Authentication required")
+		return
+	}
+
+	if err := h.sessions.Delete(r.Context(), session.TokenHash); err != nil {
+		WriteStoreError(w, r, err, "Session", "Failed to log out")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,11 @@
+package handlers
+
+import (
+	"net/http"
+)
+
+// forbidden writes the response every handler uses when the authorization
+// policy denies an action
+func forbidden(w http.ResponseWriter, r *http.Request) {
+	WriteError(w, r, http.StatusForbidden, CodeForbidden, "You do not have permission to perform this action")
+}
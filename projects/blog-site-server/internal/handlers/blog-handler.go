@@ -1,35 +1,55 @@
 package handlers
 
 import (
+	"blog-app/internal/auth"
 	"blog-app/internal/models"
 	"blog-app/internal/repository"
-	"database/sql"
+	"blog-app/internal/validate"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type BlogHandler struct {
-	repo *repository.BlogRepository
+	repo repository.BlogStore
 }
 
-func NewBlogHandler(repo *repository.BlogRepository) *BlogHandler {
+func NewBlogHandler(repo repository.BlogStore) *BlogHandler {
 	return &BlogHandler{repo: repo}
 }
 
 // CreateBlog handles the creation of a new blog post
 func (h *BlogHandler) CreateBlog(w http.ResponseWriter, r *http.Request) {
 	var blog models.Blog
-	if err := json.NewDecoder(r.Body).Decode(&blog); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := decodeJSON(r, &blog); err != nil {
+		writeDecodeError(w, r, err)
 		return
 	}
 
-	if err := h.repo.Create(&blog); err != nil {
-		http.Error(w, "Failed to create blog", http.StatusInternalServerError)
+	// Posts are attributed to the caller unless an editor publishes on
+	// someone else's behalf
+	current := auth.UserFromContext(r.Context())
+	if blog.AuthorID == 0 && current != nil {
+		blog.AuthorID = current.ID
+	}
+	if !auth.CanPublishAs(current, blog.AuthorID) {
+		forbidden(w, r)
+		return
+	}
+
+	if !validateBlog(w, r, &blog) {
+		return
+	}
+
+	if err := h.repo.Create(r.Context(), &blog); err != nil {
+		WriteStoreError(w, r, err, "Blog", "Failed to create blog")
 		return
 	}
 
+	setETag(w, blog.Version)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(blog)
@@ -40,34 +60,69 @@ func (h *BlogHandler) GetBlog(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid blog ID", http.StatusBadRequest)
+		WriteError(w, r, http.StatusBadRequest, CodeInvalidID, "Invalid blog ID")
 		return
 	}
 
-	blog, err := h.repo.GetByID(id)
+	blog, err := h.repo.GetByID(r.Context(), id)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			http.Error(w, "Blog not found", http.StatusNotFound)
-		} else {
-			http.Error(w, "Failed to get blog", http.StatusInternalServerError)
-		}
+		WriteStoreError(w, r, err, "Blog", "Failed to get blog")
 		return
 	}
 
+	setETag(w, blog.Version)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(blog)
 }
 
-// GetAllBlogs retrieves all blog posts
+// GetAllBlogs retrieves one page of blog posts, optionally filtered by
+// author_id, created_after, created_before, updated_since and tag and
+// ordered by the sort parameter. tag takes comma-separated tags, matched
+// as any of them unless tag_mode=all.
 func (h *BlogHandler) GetAllBlogs(w http.ResponseWriter, r *http.Request) {
-	blogs, err := h.repo.GetAll()
+	filter, err := parseBlogFilter(r)
 	if err != nil {
-		http.Error(w, "Failed to get blogs", http.StatusInternalServerError)
+		WriteError(w, r, http.StatusBadRequest, CodeInvalidParameter, err.Error())
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(blogs)
+	page, err := parsePage(r)
+	if err != nil {
+		writeParamError(w, r, err)
+		return
+	}
+
+	blogs, next, err := h.repo.GetAll(r.Context(), filter, page)
+	if err != nil {
+		WriteStoreError(w, r, err, "Blog", "Failed to get blogs")
+		return
+	}
+
+	writePage(w, blogs, next)
+}
+
+// SearchBlogs runs a full-text search over blog titles and content. The q
+// parameter accepts "quoted phrases" and prefix* words.
+func (h *BlogHandler) SearchBlogs(w http.ResponseWriter, r *http.Request) {
+	search, err := repository.ParseSearchQuery(r.URL.Query().Get("q"))
+	if err != nil {
+		WriteError(w, r, http.StatusBadRequest, CodeInvalidParameter, err.Error())
+		return
+	}
+
+	page, err := parsePage(r)
+	if err != nil {
+		writeParamError(w, r, err)
+		return
+	}
+
+	results, next, err := h.repo.Search(r.Context(), search, page)
+	if err != nil {
+		WriteStoreError(w, r, err, "Blog", "Failed to search blogs")
+		return
+	}
+
+	writePage(w, results, next)
 }
 
 // UpdateBlog updates an existing blog post
@@ -75,22 +130,86 @@ func (h *BlogHandler) UpdateBlog(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid blog ID", http.StatusBadRequest)
+		WriteError(w, r, http.StatusBadRequest, CodeInvalidID, "Invalid blog ID")
+		return
+	}
+
+	ifVersion, ok := requireIfMatch(w, r)
+	if !ok {
 		return
 	}
 
 	var blog models.Blog
-	if err := json.NewDecoder(r.Body).Decode(&blog); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := decodeJSON(r, &blog); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+
+	if !validateBlog(w, r, &blog) {
+		return
+	}
+
+	if _, ok := h.authorize(w, r, id); !ok {
 		return
 	}
 
 	blog.ID = id
-	if err := h.repo.Update(&blog); err != nil {
-		http.Error(w, "Failed to update blog", http.StatusInternalServerError)
+	if err := h.repo.Update(r.Context(), &blog, ifVersion); err != nil {
+		WriteStoreError(w, r, err, "Blog", "Failed to update blog")
 		return
 	}
 
+	setETag(w, blog.Version)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(blog)
+}
+
+// PatchBlog applies a JSON merge patch to a blog post, changing only the
+// fields present in the body
+func (h *BlogHandler) PatchBlog(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		WriteError(w, r, http.StatusBadRequest, CodeInvalidID, "Invalid blog ID")
+		return
+	}
+
+	ifVersion, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	var patch models.BlogPatch
+	if err := decodeMergePatch(r, &patch); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+	if err := patch.Validate(); err != nil {
+		validationFailed(w, r, fieldErrors("", err)...)
+		return
+	}
+
+	existing, ok := h.authorize(w, r, id)
+	if !ok {
+		return
+	}
+
+	candidate := *existing
+	patch.ApplyTo(&candidate, time.Now())
+	if !validateBlog(w, r, &candidate) {
+		return
+	}
+	if patch.Tags.Set {
+		patch.Tags.Value = candidate.Tags
+	}
+
+	blog, err := h.repo.Patch(r.Context(), id, &patch, ifVersion)
+	if err != nil {
+		WriteStoreError(w, r, err, "Blog", "Failed to update blog")
+		return
+	}
+
+	setETag(w, blog.Version)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(blog)
 }
@@ -100,14 +219,122 @@ func (h *BlogHandler) DeleteBlog(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid blog ID", http.StatusBadRequest)
+		WriteError(w, r, http.StatusBadRequest, CodeInvalidID, "Invalid blog ID")
+		return
+	}
+
+	ifVersion, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	if _, ok := h.authorize(w, r, id); !ok {
 		return
 	}
 
-	if err := h.repo.Delete(id); err != nil {
-		http.Error(w, "Failed to delete blog", http.StatusInternalServerError)
+	if err := h.repo.Delete(r.Context(), id, ifVersion); err != nil {
+		WriteStoreError(w, r, err, "Blog", "Failed to delete blog")
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// validateBlog checks the fields of a blog post and normalizes its tags,
+// writing the error response and returning false if any are invalid
+func validateBlog(w http.ResponseWriter, r *http.Request, blog *models.Blog) bool {
+	fields := fieldErrors("", validate.Struct(blog))
+	tags, err := models.NormalizeTags(blog.Tags)
+	if err != nil {
+		fields = append(fields, fieldErrors("tags", err)...)
+	}
+	if len(fields) > 0 {
+		validationFailed(w, r, fields...)
+		return false
+	}
+	blog.Tags = tags
+	return true
+}
+
+// authorize loads the blog post and checks the caller may modify it,
+// writing the error response and returning false otherwise
+func (h *BlogHandler) authorize(w http.ResponseWriter, r *http.Request, id int64) (*models.Blog, bool) {
+	blog, err := h.repo.GetByID(r.Context(), id)
+	if err != nil {
+		WriteStoreError(w, r, err, "Blog", "Failed to get blog")
+		return nil, false
+	}
+
+	if !auth.CanModifyBlog(auth.UserFromContext(r.Context()), blog) {
+		forbidden(w, r)
+		return nil, false
+	}
+	return blog, true
+}
+
+// parseBlogFilter reads the filtering and sorting query parameters of
+// GET /blogs
+func parseBlogFilter(r *http.Request) (repository.BlogFilter, error) {
+	query := r.URL.Query()
+	var filter repository.BlogFilter
+
+	if authorStr := query.Get("author_id"); authorStr != "" {
+		authorID, err := strconv.ParseInt(authorStr, 10, 64)
+		if err != nil {
+			return filter, fmt.Errorf("author_id must be an integer")
+		}
+		filter.AuthorID = &authorID
+	}
+
+	if tagStr := query.Get("tag"); tagStr != "" {
+		tags, err := models.NormalizeTags(strings.Split(tagStr, ","))
+		if err != nil {
+			return filter, fmt.Errorf("tag: %v", err)
+		}
+		filter.Tags = tags
+	}
+	switch query.Get("tag_mode") {
+	case "", "any":
+	case "all":
+		filter.MatchAllTags = true
+	default:
+		return filter, fmt.Errorf("tag_mode must be any or all")
+	}
+
+	times := []struct {
+		name   string
+		target **time.Time
+	}{
+		{"created_after", &filter.CreatedAfter},
+		{"created_before", &filter.CreatedBefore},
+		{"updated_since", &filter.UpdatedSince},
+	}
+	for _, param := range times {
+		value := query.Get(param.name)
+		if value == "" {
+			continue
+		}
+		t, err := parseTimeParam(value)
+		if err != nil {
+			return filter, fmt.Errorf("%s must be an RFC 3339 timestamp or YYYY-MM-DD date", param.name)
+		}
+		*param.target = &t
+	}
+
+	if sort := query.Get("sort"); sort != "" {
+		if !repository.ValidBlogSort(sort) {
+			return filter, fmt.Errorf("sort must be one of %s", strings.Join(repository.BlogSorts(), ", "))
+		}
+		filter.Sort = sort
+	}
+
+	return filter, nil
+}
+
+// parseTimeParam accepts a full RFC 3339 timestamp or a bare date
+func parseTimeParam(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
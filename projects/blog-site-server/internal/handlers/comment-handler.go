@@ -1,35 +1,84 @@
 package handlers
 
 import (
+	"blog-app/internal/auth"
 	"blog-app/internal/models"
 	"blog-app/internal/repository"
+	"blog-app/internal/validate"
 	"database/sql"
 	"encoding/json"
 	"net/http"
 	"strconv"
+	"time"
 )
 
 type CommentHandler struct {
-	repo *repository.CommentRepository
+	repo         repository.CommentStore
+	blogs        repository.BlogStore
+	maxTreeDepth int
 }
 
-func NewCommentHandler(repo *repository.CommentRepository) *CommentHandler {
-	return &CommentHandler{repo: repo}
+func NewCommentHandler(repo repository.CommentStore, blogs repository.BlogStore, maxTreeDepth int) *CommentHandler {
+	return &CommentHandler{repo: repo, blogs: blogs, maxTreeDepth: maxTreeDepth}
 }
 
 // CreateComment handles the creation of a new comment
 func (h *CommentHandler) CreateComment(w http.ResponseWriter, r *http.Request) {
 	var comment models.Comment
-	if err := json.NewDecoder(r.Body).Decode(&comment); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := decodeJSON(r, &comment); err != nil {
+		writeDecodeError(w, r, err)
 		return
 	}
 
-	if err := h.repo.Create(&comment); err != nil {
-		http.Error(w, "Failed to create comment", http.StatusInternalServerError)
+	// Comments are always posted as the caller
+	current := auth.UserFromContext(r.Context())
+	if current == nil {
+		forbidden(w, r)
 		return
 	}
+	comment.UserID = current.ID
 
+	if err := validate.Struct(&comment); err != nil {
+		validationFailed(w, r, fieldErrors("", err)...)
+		return
+	}
+
+	if _, err := h.blogs.GetByID(r.Context(), comment.PostID); err != nil {
+		if err == sql.ErrNoRows {
+			validationFailed(w, r, FieldError{Field: "post_id", Detail: "does not refer to an existing blog post"})
+		} else {
+			WriteStoreError(w, r, err, "Blog", "Failed to get blog")
+		}
+		return
+	}
+
+	// Replies must stay within the parent's post
+	if comment.ParentID != nil {
+		parent, err := h.repo.GetByID(r.Context(), *comment.ParentID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				WriteError(w, r, http.StatusBadRequest, CodeInvalidParent, "Parent comment not found")
+			} else {
+				WriteStoreError(w, r, err, "Parent comment", "Failed to get parent comment")
+			}
+			return
+		}
+		if parent.PostID != comment.PostID {
+			WriteError(w, r, http.StatusBadRequest, CodeInvalidParent, "Parent comment belongs to a different post")
+			return
+		}
+		if parent.Deleted {
+			WriteError(w, r, http.StatusBadRequest, CodeCommentDeleted, "Cannot reply to a deleted comment")
+			return
+		}
+	}
+
+	if err := h.repo.Create(r.Context(), &comment); err != nil {
+		WriteStoreError(w, r, err, "Comment", "Failed to create comment")
+		return
+	}
+
+	setETag(w, comment.Version)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(comment)
@@ -40,41 +89,105 @@ func (h *CommentHandler) GetComment(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid comment ID", http.StatusBadRequest)
+		WriteError(w, r, http.StatusBadRequest, CodeInvalidID, "Invalid comment ID")
 		return
 	}
 
-	comment, err := h.repo.GetByID(id)
+	comment, err := h.repo.GetByID(r.Context(), id)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			http.Error(w, "Comment not found", http.StatusNotFound)
-		} else {
-			http.Error(w, "Failed to get comment", http.StatusInternalServerError)
-		}
+		WriteStoreError(w, r, err, "Comment", "Failed to get comment")
 		return
 	}
 
+	setETag(w, comment.Version)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(comment)
 }
 
-// GetCommentsForBlog retrieves all comments for a specific blog post
+// GetCommentsForBlog retrieves one page of comments for a specific blog
+// post. With format=tree the page holds top-level comments with their
+// replies nested below them, down to the depth parameter (capped at the
+// server's maximum).
 func (h *CommentHandler) GetCommentsForBlog(w http.ResponseWriter, r *http.Request) {
 	blogIDStr := r.PathValue("blogID")
 	blogID, err := strconv.ParseInt(blogIDStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid blog ID", http.StatusBadRequest)
+		WriteError(w, r, http.StatusBadRequest, CodeInvalidID, "Invalid blog ID")
 		return
 	}
 
-	comments, err := h.repo.GetByBlogID(blogID)
+	page, err := parsePage(r)
 	if err != nil {
-		http.Error(w, "Failed to get comments", http.StatusInternalServerError)
+		writeParamError(w, r, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(comments)
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "flat":
+		comments, next, err := h.repo.GetByBlogID(r.Context(), blogID, page)
+		if err != nil {
+			WriteStoreError(w, r, err, "Comment", "Failed to get comments")
+			return
+		}
+		writePage(w, comments, next)
+	case "tree":
+		depth := h.maxTreeDepth
+		if depthStr := r.URL.Query().Get("depth"); depthStr != "" {
+			depth, err = strconv.Atoi(depthStr)
+			if err != nil || depth < 0 {
+				WriteError(w, r, http.StatusBadRequest, CodeInvalidParameter, "depth must be a non-negative integer")
+				return
+			}
+			depth = min(depth, h.maxTreeDepth)
+		}
+
+		roots, next, err := h.repo.GetRootsByBlogID(r.Context(), blogID, page)
+		if err != nil {
+			WriteStoreError(w, r, err, "Comment", "Failed to get comments")
+			return
+		}
+
+		rootIDs := make([]int64, len(roots))
+		for i, root := range roots {
+			rootIDs[i] = root.ID
+		}
+		replies, err := h.repo.GetReplies(r.Context(), rootIDs, depth)
+		if err != nil {
+			WriteStoreError(w, r, err, "Comment", "Failed to get comments")
+			return
+		}
+
+		buildCommentTree(roots, replies, depth)
+		writePage(w, roots, next)
+	default:
+		WriteError(w, r, http.StatusBadRequest, CodeInvalidParameter, "format must be flat or tree")
+	}
+}
+
+// buildCommentTree nests replies (oldest first) below their parents,
+// dropping those deeper than maxDepth and flagging where that happened
+func buildCommentTree(roots, replies []*models.Comment, maxDepth int) {
+	byID := make(map[int64]*models.Comment, len(roots)+len(replies))
+	depth := make(map[int64]int, len(roots)+len(replies))
+	for _, root := range roots {
+		byID[root.ID] = root
+	}
+
+	// Parents are always older than their replies, so they are placed first
+	for _, reply := range replies {
+		parent := byID[*reply.ParentID]
+		if parent == nil {
+			continue
+		}
+		d := depth[parent.ID] + 1
+		if d > maxDepth {
+			parent.HasMoreReplies = true
+			continue
+		}
+		depth[reply.ID] = d
+		byID[reply.ID] = reply
+		parent.Replies = append(parent.Replies, reply)
+	}
 }
 
 // UpdateComment updates an existing comment
@@ -82,22 +195,96 @@ func (h *CommentHandler) UpdateComment(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid comment ID", http.StatusBadRequest)
+		WriteError(w, r, http.StatusBadRequest, CodeInvalidID, "Invalid comment ID")
+		return
+	}
+
+	ifVersion, ok := requireIfMatch(w, r)
+	if !ok {
 		return
 	}
 
 	var comment models.Comment
-	if err := json.NewDecoder(r.Body).Decode(&comment); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := decodeJSON(r, &comment); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+
+	existing, ok := h.authorize(w, r, id)
+	if !ok {
+		return
+	}
+	if existing.Deleted {
+		WriteError(w, r, http.StatusBadRequest, CodeCommentDeleted, "Cannot edit a deleted comment")
+		return
+	}
+
+	// Only the content can change; the rest is taken from the stored comment
+	comment.PostID = existing.PostID
+	if err := validate.Struct(&comment); err != nil {
+		validationFailed(w, r, fieldErrors("", err)...)
 		return
 	}
 
 	comment.ID = id
-	if err := h.repo.Update(&comment); err != nil {
-		http.Error(w, "Failed to update comment", http.StatusInternalServerError)
+	if err := h.repo.Update(r.Context(), &comment, ifVersion); err != nil {
+		WriteStoreError(w, r, err, "Comment", "Failed to update comment")
 		return
 	}
 
+	setETag(w, comment.Version)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(comment)
+}
+
+// PatchComment applies a JSON merge patch to a comment, changing only the
+// fields present in the body
+func (h *CommentHandler) PatchComment(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		WriteError(w, r, http.StatusBadRequest, CodeInvalidID, "Invalid comment ID")
+		return
+	}
+
+	ifVersion, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	var patch models.CommentPatch
+	if err := decodeMergePatch(r, &patch); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+	if err := patch.Validate(); err != nil {
+		validationFailed(w, r, fieldErrors("", err)...)
+		return
+	}
+
+	existing, ok := h.authorize(w, r, id)
+	if !ok {
+		return
+	}
+	if existing.Deleted {
+		WriteError(w, r, http.StatusBadRequest, CodeCommentDeleted, "Cannot edit a deleted comment")
+		return
+	}
+
+	candidate := *existing
+	patch.ApplyTo(&candidate, time.Now())
+	if err := validate.Struct(&candidate); err != nil {
+		validationFailed(w, r, fieldErrors("", err)...)
+		return
+	}
+
+	comment, err := h.repo.Patch(r.Context(), id, &patch, ifVersion)
+	if err != nil {
+		WriteStoreError(w, r, err, "Comment", "Failed to update comment")
+		return
+	}
+
+	setETag(w, comment.Version)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(comment)
 }
@@ -107,14 +294,39 @@ func (h *CommentHandler) DeleteComment(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid comment ID", http.StatusBadRequest)
+		WriteError(w, r, http.StatusBadRequest, CodeInvalidID, "Invalid comment ID")
+		return
+	}
+
+	ifVersion, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	if _, ok := h.authorize(w, r, id); !ok {
 		return
 	}
 
-	if err := h.repo.Delete(id); err != nil {
-		http.Error(w, "Failed to delete comment", http.StatusInternalServerError)
+	if err := h.repo.Delete(r.Context(), id, ifVersion); err != nil {
+		WriteStoreError(w, r, err, "Comment", "Failed to delete comment")
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// authorize loads the comment and checks the caller may modify it,
+// writing the error response and returning false otherwise
+func (h *CommentHandler) authorize(w http.ResponseWriter, r *http.Request, id int64) (*models.Comment, bool) {
+	comment, err := h.repo.GetByID(r.Context(), id)
+	if err != nil {
+		WriteStoreError(w, r, err, "Comment", "Failed to get comment")
+		return nil, false
+	}
+
+	if !auth.CanModifyComment(auth.UserFromContext(r.Context()), comment) {
+		forbidden(w, r)
+		return nil, false
+	}
+	return comment, true
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"blog-app/internal/models"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// renderTree writes a comment tree as "id[replies]", with "+" after the
+// comments whose replies were cut off
+func renderTree(comments []*models.Comment) string {
+	parts := make([]string, len(comments))
+	for i, comment := range comments {
+		s := strconv.FormatInt(comment.ID, 10)
+		if comment.HasMoreReplies {
+			s += "+"
+		}
+		if len(comment.Replies) > 0 {
+			s += "[" + renderTree(comment.Replies) + "]"
+		}
+		parts[i] = s
+	}
+	return strings.Join(parts, ",")
+}
+
+func TestBuildCommentTree(t *testing.T) {
+	// Comments are given as {id, parent}; roots are 1 and 5
+	thread := [][2]int64{{2, 1}, {3, 2}, {4, 1}, {6, 5}, {7, 3}, {8, 99}}
+
+	tests := []struct {
+		name     string
+		replies  int // how many of thread are passed in, oldest first
+		maxDepth int
+		want     string
+	}{
+		{"no replies", 0, 3, "1,5"},
+		{"depth zero flags roots with replies", 4, 0, "1+,5+"},
+		{"depth one", 4, 1, "1[2+,4],5[6]"},
+		{"depth two", 5, 2, "1[2[3+],4],5[6]"},
+		{"full depth", 5, 3, "1[2[3[7]],4],5[6]"},
+		{"reply to an unknown parent is dropped", 6, 3, "1[2[3[7]],4],5[6]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			roots := []*models.Comment{{ID: 1}, {ID: 5}}
+			var replies []*models.Comment
+			for _, c := range thread[:tt.replies] {
+				parentID := c[1]
+				replies = append(replies, &models.Comment{ID: c[0], ParentID: &parentID})
+			}
+
+			buildCommentTree(roots, replies, tt.maxDepth)
+			if got := renderTree(roots); got != tt.want {
+				t.Errorf("tree = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
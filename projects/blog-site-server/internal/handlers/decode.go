@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// errTrailingData is returned when a body holds more than one JSON value
+var errTrailingData = errors.New("unexpected data after the JSON value")
+
+// decodeJSON decodes a request body holding exactly one JSON value into v.
+// Unknown fields are rejected rather than silently ignored.
+func decodeJSON(r *http.Request, v any) error {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if err := decoder.Decode(&struct{}{}); err != io.EOF {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			return err
+		}
+		return errTrailingData
+	}
+	return nil
+}
+
+// writeDecodeError responds to a failed decodeJSON or decodeMergePatch
+func writeDecodeError(w http.ResponseWriter, r *http.Request, err error) {
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		WriteError(w, r, http.StatusRequestEntityTooLarge, CodeBodyTooLarge, BodyTooLargeDetail(maxBytesErr.Limit))
+	case errors.Is(err, errUnsupportedPatchType):
+		WriteError(w, r, http.StatusUnsupportedMediaType, CodeUnsupportedMediaType, err.Error())
+	default:
+		WriteError(w, r, http.StatusBadRequest, CodeInvalidBody, "Invalid request body: "+err.Error())
+	}
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"blog-app/internal/repository"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// etag renders a resource version as a strong entity tag
+func etag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// setETag advertises the version of the resource in the response
+func setETag(w http.ResponseWriter, version int64) {
+	w.Header().Set("ETag", etag(version))
+}
+
+// requireIfMatch reads the If-Match precondition that every write must
+// carry and returns the version it names, or repository.AnyVersion for
+// "*". It writes the error response and returns false when the header is
+// missing or can never match: If-Match uses strong comparison, so weak and
+// foreign tags fail outright.
+func requireIfMatch(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		WriteError(w, r, http.StatusPreconditionRequired, CodePreconditionRequired, "If-Match header is required; send the ETag from a previous GET")
+		return 0, false
+	}
+	if header == "*" {
+		return repository.AnyVersion, true
+	}
+
+	var versions []int64
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			continue
+		}
+		version, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64)
+		if err != nil || version <= 0 {
+			continue
+		}
+		versions = append(versions, version)
+	}
+
+	switch len(versions) {
+	case 0:
+		preconditionFailed(w, r)
+		return 0, false
+	case 1:
+		return versions[0], true
+	default:
+		WriteError(w, r, http.StatusBadRequest, CodeInvalidRequest, "If-Match must name a single entity tag")
+		return 0, false
+	}
+}
+
+// preconditionFailed responds to a write whose If-Match no longer matches
+func preconditionFailed(w http.ResponseWriter, r *http.Request) {
+	WriteError(w, r, http.StatusPreconditionFailed, CodePreconditionFailed, "Resource has been modified; fetch it again and retry")
+}
@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"blog-app/internal/auth"
+	"blog-app/internal/db"
+	"blog-app/internal/metrics"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+)
+
+// readyTimeout bounds the database checks of a readiness probe
+const readyTimeout = 2 * time.Second
+
+// HealthHandler serves the probes used by load balancers and orchestrators
+// and the admin diagnostics pages. database is nil with in-memory storage.
+type HealthHandler struct {
+	database     *sql.DB
+	storage      string
+	metrics      *metrics.Registry
+	started      time.Time
+	shuttingDown atomic.Bool
+}
+
+func NewHealthHandler(database *sql.DB, storage string, registry *metrics.Registry) *HealthHandler {
+	return &HealthHandler{database: database, storage: storage, metrics: registry, started: time.Now()}
+}
+
+// ShuttingDown marks the server as going away so readiness fails from now
+// on. It should be called before the listeners close, while probes can
+// still observe it.
+func (h *HealthHandler) ShuttingDown() {
+	h.shuttingDown.Store(true)
+}
+
+// readyResponse reports the outcome of each readiness check
+type readyResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+// Healthz reports that the process is alive and serving requests
+func (h *HealthHandler) Healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// Readyz reports whether the server should receive traffic: the database
+// answers, its schema is fully migrated and no shutdown is in progress
+func (h *HealthHandler) Readyz(w http.ResponseWriter, r *http.Request) {
+	checks := make(map[string]string)
+	ready := true
+	fail := func(check, message string) {
+		checks[check] = message
+		ready = false
+	}
+
+	if h.shuttingDown.Load() {
+		fail("shutdown", "in progress")
+	} else {
+		checks["shutdown"] = "ok"
+	}
+
+	if h.database != nil {
+		ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+		defer cancel()
+
+		if err := h.database.PingContext(ctx); err != nil {
+			slog.WarnContext(ctx, "Readiness check: database ping failed", "error", err)
+			fail("database", "unreachable")
+		} else {
+			checks["database"] = "ok"
+			pending, err := db.CheckSchema(ctx, h.database)
+			switch {
+			case err != nil:
+				slog.WarnContext(ctx, "Readiness check: schema check failed", "error", err)
+				fail("migrations", "check failed")
+			case pending > 0:
+				fail("migrations", fmt.Sprintf("%d pending", pending))
+			default:
+				checks["migrations"] = "ok"
+			}
+		}
+	}
+
+	response := readyResponse{Status: "ready", Checks: checks}
+	status := http.StatusOK
+	if !ready {
+		response.Status = "not ready"
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// buildInfo identifies the running binary
+type buildInfo struct {
+	GoVersion string `json:"go_version"`
+	Module    string `json:"module,omitempty"`
+	Version   string `json:"version,omitempty"`
+	Revision  string `json:"revision,omitempty"`
+	BuildTime string `json:"build_time,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+}
+
+// statusResponse is the payload for GET /debug/status
+type statusResponse struct {
+	Build         buildInfo  `json:"build"`
+	Storage       string     `json:"storage"`
+	StartedAt     time.Time  `json:"started_at"`
+	Uptime        string     `json:"uptime"`
+	UptimeSeconds int64      `json:"uptime_seconds"`
+	Database      *poolStats `json:"database,omitempty"`
+}
+
+// poolStats mirrors sql.DBStats
+type poolStats struct {
+	MaxOpenConnections int    `json:"max_open_connections"`
+	OpenConnections    int    `json:"open_connections"`
+	InUse              int    `json:"in_use"`
+	Idle               int    `json:"idle"`
+	WaitCount          int64  `json:"wait_count"`
+	WaitDuration       string `json:"wait_duration"`
+	MaxIdleClosed      int64  `json:"max_idle_closed"`
+	MaxIdleTimeClosed  int64  `json:"max_idle_time_closed"`
+	MaxLifetimeClosed  int64  `json:"max_lifetime_closed"`
+}
+
+// Status reports build information, uptime and connection pool statistics
+// to admins
+func (h *HealthHandler) Status(w http.ResponseWriter, r *http.Request) {
+	if !auth.IsAdmin(auth.UserFromContext(r.Context())) {
+		forbidden(w, r)
+		return
+	}
+
+	uptime := time.Since(h.started)
+	response := statusResponse{
+		Build:         readBuildInfo(),
+		Storage:       h.storage,
+		StartedAt:     h.started,
+		Uptime:        uptime.Round(time.Second).String(),
+		UptimeSeconds: int64(uptime.Seconds()),
+	}
+	if h.database != nil {
+		stats := h.database.Stats()
+		response.Database = &poolStats{
+			MaxOpenConnections: stats.MaxOpenConnections,
+			OpenConnections:    stats.OpenConnections,
+			InUse:              stats.InUse,
+			Idle:               stats.Idle,
+			WaitCount:          stats.WaitCount,
+			WaitDuration:       stats.WaitDuration.String(),
+			MaxIdleClosed:      stats.MaxIdleClosed,
+			MaxIdleTimeClosed:  stats.MaxIdleTimeClosed,
+			MaxLifetimeClosed:  stats.MaxLifetimeClosed,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(response)
+}
+
+// Metrics serves the Prometheus metrics to admins
+func (h *HealthHandler) Metrics(w http.ResponseWriter, r *http.Request) {
+	if !auth.IsAdmin(auth.UserFromContext(r.Context())) {
+		forbidden(w, r)
+		return
+	}
+	h.metrics.Handler().ServeHTTP(w, r)
+}
+
+// readBuildInfo extracts the module version and VCS stamp of the binary
+func readBuildInfo() buildInfo {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return buildInfo{GoVersion: "unknown"}
+	}
+
+	build := buildInfo{
+		GoVersion: info.GoVersion,
+		Module:    info.Main.Path,
+		Version:   info.Main.Version,
+	}
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			build.Revision = setting.Value
+		case "vcs.time":
+			build.BuildTime = setting.Value
+		case "vcs.modified":
+			build.Modified = setting.Value == "true"
+		}
+	}
+	return build
+}
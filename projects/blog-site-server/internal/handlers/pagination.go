@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"blog-app/internal/repository"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+)
+
+// pageResponse is the envelope returned by every list endpoint
+type pageResponse[T any] struct {
+	Data       []T     `json:"data"`
+	NextCursor *string `json:"next_cursor"`
+}
+
+// paramError reports an invalid query parameter. It wraps the cause so
+// that errors.Is still recognizes repository.ErrInvalidCursor.
+type paramError struct {
+	err error
+}
+
+func (e *paramError) Error() string {
+	return e.err.Error()
+}
+
+func (e *paramError) Unwrap() error {
+	return e.err
+}
+
+// parsePage reads the limit and cursor query parameters, failing with a
+// *paramError. Limits above repository.MaxPageSize are clamped rather than
+// rejected.
+func parsePage(r *http.Request) (repository.Page, error) {
+	var page repository.Page
+
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		limit, err := strconv.Atoi(limitStr)
+		if err != nil || limit <= 0 {
+			return page, &paramError{errors.New("limit must be a positive integer")}
+		}
+		page.Limit = min(limit, repository.MaxPageSize)
+	}
+
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		after, err := repository.DecodeCursor(cursor)
+		if err != nil {
+			return page, &paramError{err}
+		}
+		page.After = after
+	}
+
+	return page, nil
+}
+
+// writeParamError writes a 400 for an invalid query parameter. Cursors get
+// their own code whether they fail to decode or belong to another listing.
+func writeParamError(w http.ResponseWriter, r *http.Request, err error) {
+	code := CodeInvalidParameter
+	if errors.Is(err, repository.ErrInvalidCursor) {
+		code = CodeInvalidCursor
+	}
+	WriteError(w, r, http.StatusBadRequest, code, err.Error())
+}
+
+// writePage encodes a page of results in the list envelope
+func writePage[T any](w http.ResponseWriter, items []T, next *repository.Cursor) {
+	resp := pageResponse[T]{Data: items}
+	if resp.Data == nil {
+		resp.Data = []T{}
+	}
+	if next != nil {
+		token := repository.EncodeCursor(next)
+		resp.NextCursor = &token
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"errors"
+	"mime"
+	"net/http"
+)
+
+// mergePatchType is the media type of RFC 7396 JSON Merge Patch documents
+const mergePatchType = "application/merge-patch+json"
+
+// errUnsupportedPatchType is returned for PATCH bodies that are not JSON
+var errUnsupportedPatchType = errors.New("PATCH bodies must be " + mergePatchType + " or application/json")
+
+// decodeMergePatch decodes a merge patch document into one of the
+// models.*Patch types. Members the patch type does not know are rejected
+// rather than silently ignored.
+func decodeMergePatch(r *http.Request, patch any) error {
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, err := mime.ParseMediaType(contentType)
+		if err != nil || (mediaType != mergePatchType && mediaType != "application/json") {
+			return errUnsupportedPatchType
+		}
+	}
+
+	return decodeJSON(r, patch)
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"blog-app/internal/auth"
+	"blog-app/internal/models"
+	"blog-app/internal/repository"
+	"blog-app/internal/requestid"
+	"blog-app/internal/validate"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+// Stable error codes. Clients branch on these rather than on detail text,
+// so existing values must never change meaning.
+const (
+	CodeInvalidRequest       = "invalid_request"
+	CodeInvalidBody          = "invalid_body"
+	CodeInvalidID            = "invalid_id"
+	CodeInvalidParameter     = "invalid_parameter"
+	CodeInvalidCursor        = "invalid_cursor"
+	CodeValidationFailed     = "validation_failed"
+	CodeUnauthorized         = "unauthorized"
+	CodeInvalidCredentials   = "invalid_credentials"
+	CodeAccountInactive      = "account_inactive"
+	CodeForbidden            = "forbidden"
+	CodeNotFound             = "not_found"
+	CodeCommentDeleted       = "comment_deleted"
+	CodeInvalidParent        = "invalid_parent"
+	CodeInvalidReference     = "invalid_reference"
+	CodeDuplicate            = "duplicate"
+	CodeValueTooLong         = "value_too_long"
+	CodeConstraintViolation  = "constraint_violation"
+	CodeUnsupportedMediaType = "unsupported_media_type"
+	CodeBodyTooLarge         = "body_too_large"
+	CodePreconditionRequired = "precondition_required"
+	CodePreconditionFailed   = "precondition_failed"
+	CodeRateLimited          = "rate_limited"
+	CodeTimeout              = "timeout"
+	CodeRequestCanceled      = "request_canceled"
+	CodeInternal             = "internal_error"
+)
+
+// problemContentType is the media type of RFC 7807 problem details
+const problemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details body. Type, Title, Instance and
+// RequestID are filled in by WriteProblem.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Code      string       `json:"code"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError describes one invalid input field
+type FieldError struct {
+	Field  string `json:"field"`
+	Detail string `json:"detail"`
+}
+
+// WriteProblem writes p as application/problem+json
+func WriteProblem(w http.ResponseWriter, r *http.Request, p Problem) {
+	if p.Type == "" {
+		p.Type = "about:blank"
+	}
+	if p.Title == "" {
+		p.Title = http.StatusText(p.Status)
+	}
+	p.Instance = r.URL.Path
+	p.RequestID = requestid.FromContext(r.Context())
+
+	w.Header().Set("Content-Type", problemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
+
+// WriteError writes a problem without field errors
+func WriteError(w http.ResponseWriter, r *http.Request, status int, code, detail string) {
+	WriteProblem(w, r, Problem{Status: status, Code: code, Detail: detail})
+}
+
+// BodyTooLargeDetail is the detail of a 413 for a body over limit bytes
+func BodyTooLargeDetail(limit int64) string {
+	return fmt.Sprintf("Request body must not exceed %d bytes", limit)
+}
+
+// internalError logs the cause of an unexpected failure, tagged with the
+// request ID, and writes a 500 that reveals nothing about it
+func internalError(w http.ResponseWriter, r *http.Request, err error, detail string) {
+	slog.ErrorContext(r.Context(), detail, "error", err)
+	WriteError(w, r, http.StatusInternalServerError, CodeInternal, detail)
+}
+
+// validationFailed writes a 422 listing the offending fields
+func validationFailed(w http.ResponseWriter, r *http.Request, fields ...FieldError) {
+	WriteProblem(w, r, Problem{
+		Status: http.StatusUnprocessableEntity,
+		Code:   CodeValidationFailed,
+		Detail: "The request contains invalid fields",
+		Errors: fields,
+	})
+}
+
+// fieldErrors breaks a validation error into per-field errors. field names
+// the input when the error does not carry a field of its own.
+func fieldErrors(field string, err error) []FieldError {
+	if err == nil {
+		return nil
+	}
+
+	var validationErrs validate.Errors
+	if errors.As(err, &validationErrs) {
+		fields := make([]FieldError, len(validationErrs))
+		for i, fieldErr := range validationErrs {
+			fields[i] = FieldError{Field: fieldErr.Field, Detail: fieldErr.Detail}
+		}
+		return fields
+	}
+
+	var policyErr *auth.PolicyError
+	if errors.As(err, &policyErr) {
+		fields := make([]FieldError, len(policyErr.Violations))
+		for i, violation := range policyErr.Violations {
+			fields[i] = FieldError{Field: field, Detail: violation}
+		}
+		return fields
+	}
+
+	var nullErr *models.NullError
+	if errors.As(err, &nullErr) {
+		return []FieldError{{Field: nullErr.Field, Detail: "must not be null"}}
+	}
+
+	return []FieldError{{Field: field, Detail: err.Error()}}
+}
+
+// WriteStoreError maps an error from a repository call onto a response.
+// resource names the entity in not-found details; failure is the detail
+// for unexpected errors. A query that ran past its deadline is a 504; one
+// abandoned because the client went away is a 503 nobody will read. A
+// cursor the listing rejects is reported like an invalid parameter.
+func WriteStoreError(w http.ResponseWriter, r *http.Request, err error, resource, failure string) {
+	var constraintErr *repository.ConstraintError
+	switch {
+	case err == sql.ErrNoRows:
+		WriteError(w, r, http.StatusNotFound, CodeNotFound, resource+" not found")
+	case errors.Is(err, repository.ErrInvalidCursor):
+		writeParamError(w, r, err)
+	case errors.Is(err, repository.ErrVersionConflict):
+		preconditionFailed(w, r)
+	case errors.As(err, &constraintErr):
+		writeConstraintError(w, r, constraintErr)
+	case errors.Is(err, context.DeadlineExceeded):
+		slog.WarnContext(r.Context(), failure, "error", err)
+		WriteError(w, r, http.StatusGatewayTimeout, CodeTimeout, "The database did not respond in time")
+	case errors.Is(err, context.Canceled):
+		slog.WarnContext(r.Context(), failure, "error", err)
+		WriteError(w, r, http.StatusServiceUnavailable, CodeRequestCanceled, "The request was canceled")
+	default:
+		internalError(w, r, err, failure)
+	}
+}
+
+// writeConstraintError reports a write rejected by a database constraint:
+// duplicates conflict with existing data, dangling references are invalid
+// input and anything else is a malformed value
+func writeConstraintError(w http.ResponseWriter, r *http.Request, err *repository.ConstraintError) {
+	p := Problem{Detail: err.Error()}
+	var fieldDetail string
+	switch {
+	case errors.Is(err, repository.ErrDuplicate):
+		p.Status, p.Code, fieldDetail = http.StatusConflict, CodeDuplicate, "is already taken"
+	case errors.Is(err, repository.ErrMissingReference):
+		p.Status, p.Code, fieldDetail = http.StatusUnprocessableEntity, CodeInvalidReference, "does not refer to an existing record"
+	case errors.Is(err, repository.ErrValueTooLong):
+		p.Status, p.Code, fieldDetail = http.StatusBadRequest, CodeValueTooLong, "is too long"
+	default:
+		p.Status, p.Code, fieldDetail = http.StatusBadRequest, CodeConstraintViolation, "is not allowed"
+	}
+	if err.Field != "" {
+		p.Errors = []FieldError{{Field: err.Field, Detail: fieldDetail}}
+	}
+	WriteProblem(w, r, p)
+}
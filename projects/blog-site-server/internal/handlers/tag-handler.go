@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"blog-app/internal/models"
+	"blog-app/internal/repository"
+	"net/http"
+)
+
+type TagHandler struct {
+	repo  repository.TagStore
+	blogs repository.BlogStore
+}
+
+func NewTagHandler(repo repository.TagStore, blogs repository.BlogStore) *TagHandler {
+	return &TagHandler{repo: repo, blogs: blogs}
+}
+
+// GetAllTags retrieves one page of the tags in use, ordered by slug, with
+// the number of blog posts carrying each
+func (h *TagHandler) GetAllTags(w http.ResponseWriter, r *http.Request) {
+	page, err := parsePage(r)
+	if err != nil {
+		writeParamError(w, r, err)
+		return
+	}
+
+	tags, next, err := h.repo.GetAll(r.Context(), page)
+	if err != nil {
+		WriteStoreError(w, r, err, "Tag", "Failed to get tags")
+		return
+	}
+
+	writePage(w, tags, next)
+}
+
+// GetTagBlogs retrieves one page of the blog posts carrying a tag. The
+// slug is normalized like tag names, and the filters and sorting of
+// GET /blogs apply except for tag itself.
+func (h *TagHandler) GetTagBlogs(w http.ResponseWriter, r *http.Request) {
+	tag, err := h.repo.GetBySlug(r.Context(), models.TagSlug(r.PathValue("slug")))
+	if err != nil {
+		WriteStoreError(w, r, err, "Tag", "Failed to get tag")
+		return
+	}
+
+	filter, err := parseBlogFilter(r)
+	if err != nil {
+		WriteError(w, r, http.StatusBadRequest, CodeInvalidParameter, err.Error())
+		return
+	}
+	filter.Tags = []string{tag.Slug}
+	filter.MatchAllTags = false
+
+	page, err := parsePage(r)
+	if err != nil {
+		writeParamError(w, r, err)
+		return
+	}
+
+	blogs, next, err := h.blogs.GetAll(r.Context(), filter, page)
+	if err != nil {
+		WriteStoreError(w, r, err, "Blog", "Failed to get blogs")
+		return
+	}
+
+	writePage(w, blogs, next)
+}
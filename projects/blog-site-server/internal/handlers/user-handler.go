@@ -1,38 +1,92 @@
 package handlers
 
 import (
+	"blog-app/internal/auth"
 	"blog-app/internal/models"
 	"blog-app/internal/repository"
-	"database/sql"
+	"blog-app/internal/validate"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 )
 
 type UserHandler struct {
-	repo *repository.UserRepository
+	repo     repository.UserStore
+	sessions repository.SessionStore
+	hasher   *auth.PasswordHasher
+	policy   auth.PasswordPolicy
 }
 
-func NewUserHandler(repo *repository.UserRepository) *UserHandler {
-	return &UserHandler{repo: repo}
+func NewUserHandler(repo repository.UserStore, sessions repository.SessionStore, hasher *auth.PasswordHasher, policy auth.PasswordPolicy) *UserHandler {
+	return &UserHandler{repo: repo, sessions: sessions, hasher: hasher, policy: policy}
+}
+
+// createUserRequest is the signup payload; unlike models.User it carries
+// the plaintext password
+type createUserRequest struct {
+	models.User
+	Password string `json:"password"`
+}
+
+// updateUserRequest is the payload of a full user update. Role and status
+// are kept as stored when left out, so a PUT never changes them by omission.
+type updateUserRequest struct {
+	models.User
+	Role     *string `json:"role"`
+	IsActive *bool   `json:"is_active"`
+}
+
+// changePasswordRequest is the payload for rotating a password
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
 }
 
 // CreateUser handles the creation of a new user
 func (h *UserHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
-	var user models.User
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	var req createUserRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+
+	// Self-service signups are readers; only an admin may create accounts
+	// with elevated roles
+	user := req.User
+	if user.Role == "" {
+		user.Role = auth.DefaultRole
+	}
+
+	fields := fieldErrors("", validate.Struct(&user))
+	fields = append(fields, fieldErrors("password", h.policy.Check(req.Password))...)
+	if len(fields) > 0 {
+		validationFailed(w, r, fields...)
+		return
+	}
+
+	if user.Role != auth.DefaultRole && !auth.IsAdmin(auth.UserFromContext(r.Context())) {
+		forbidden(w, r)
 		return
 	}
 
-	if err := h.repo.Create(&user); err != nil {
-		http.Error(w, "Failed to create user", http.StatusInternalServerError)
+	hash, err := h.hasher.Hash(req.Password)
+	if err != nil {
+		internalError(w, r, err, "Failed to create user")
+		return
+	}
+
+	user.PasswordHash = hash
+	if err := h.repo.Create(r.Context(), &user); err != nil {
+		WriteStoreError(w, r, err, "User", "Failed to create user")
 		return
 	}
 
 	// Don't return password hash in response
 	user.PasswordHash = ""
 
+	setETag(w, user.Version)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(user)
@@ -43,32 +97,35 @@ func (h *UserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		WriteError(w, r, http.StatusBadRequest, CodeInvalidID, "Invalid user ID")
 		return
 	}
 
-	user, err := h.repo.GetByID(id)
+	user, err := h.repo.GetByID(r.Context(), id)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			http.Error(w, "User not found", http.StatusNotFound)
-		} else {
-			http.Error(w, "Failed to get user", http.StatusInternalServerError)
-		}
+		WriteStoreError(w, r, err, "User", "Failed to get user")
 		return
 	}
 
 	// Don't return password hash
 	user.PasswordHash = ""
 
+	setETag(w, user.Version)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(user)
 }
 
-// GetAllUsers retrieves all users
+// GetAllUsers retrieves one page of users
 func (h *UserHandler) GetAllUsers(w http.ResponseWriter, r *http.Request) {
-	users, err := h.repo.GetAll()
+	page, err := parsePage(r)
+	if err != nil {
+		writeParamError(w, r, err)
+		return
+	}
+
+	users, next, err := h.repo.GetAll(r.Context(), page)
 	if err != nil {
-		http.Error(w, "Failed to get users", http.StatusInternalServerError)
+		WriteStoreError(w, r, err, "User", "Failed to get users")
 		return
 	}
 
@@ -77,48 +134,230 @@ func (h *UserHandler) GetAllUsers(w http.ResponseWriter, r *http.Request) {
 		user.PasswordHash = ""
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(users)
+	writePage(w, users, next)
 }
 
-// UpdateUser updates an existing user
+// UpdateUser replaces the profile of an existing user. Role and is_active
+// keep their stored values unless the body sets them.
 func (h *UserHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		WriteError(w, r, http.StatusBadRequest, CodeInvalidID, "Invalid user ID")
 		return
 	}
 
-	var user models.User
-	if err := json.NewDecoder(r.Body).Decode(&user); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	ifVersion, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	var req updateUserRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+
+	current := auth.UserFromContext(r.Context())
+	if !auth.CanModifyUser(current, id) {
+		forbidden(w, r)
+		return
+	}
+
+	existing, err := h.repo.GetByID(r.Context(), id)
+	if err != nil {
+		WriteStoreError(w, r, err, "User", "Failed to get user")
+		return
+	}
+
+	user := req.User
+	// Usernames are immutable, so the stored one stands in for validation
+	user.Username = existing.Username
+	user.Role = existing.Role
+	if req.Role != nil {
+		user.Role = *req.Role
+	}
+	user.IsActive = existing.IsActive
+	if req.IsActive != nil {
+		user.IsActive = *req.IsActive
+	}
+	if err := validate.Struct(&user); err != nil {
+		validationFailed(w, r, fieldErrors("", err)...)
+		return
+	}
+
+	if (user.Role != existing.Role || user.IsActive != existing.IsActive) && !auth.CanChangeRoleOrStatus(current) {
+		forbidden(w, r)
 		return
 	}
 
 	user.ID = id
-	if err := h.repo.Update(&user); err != nil {
-		http.Error(w, "Failed to update user", http.StatusInternalServerError)
+	if err := h.repo.Update(r.Context(), &user, ifVersion); err != nil {
+		WriteStoreError(w, r, err, "User", "Failed to update user")
+		return
+	}
+
+	user.PasswordHash = ""
+
+	setETag(w, user.Version)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
+}
+
+// PatchUser applies a JSON merge patch to a user, changing only the fields
+// present in the body
+func (h *UserHandler) PatchUser(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		WriteError(w, r, http.StatusBadRequest, CodeInvalidID, "Invalid user ID")
+		return
+	}
+
+	ifVersion, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	var patch models.UserPatch
+	if err := decodeMergePatch(r, &patch); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+	if err := patch.Validate(); err != nil {
+		validationFailed(w, r, fieldErrors("", err)...)
+		return
+	}
+
+	current := auth.UserFromContext(r.Context())
+	if !auth.CanModifyUser(current, id) {
+		forbidden(w, r)
+		return
+	}
+
+	existing, err := h.repo.GetByID(r.Context(), id)
+	if err != nil {
+		WriteStoreError(w, r, err, "User", "Failed to get user")
+		return
+	}
+
+	roleChanged := patch.Role.Set && patch.Role.Value != existing.Role
+	statusChanged := patch.IsActive.Set && patch.IsActive.Value != existing.IsActive
+	if (roleChanged || statusChanged) && !auth.CanChangeRoleOrStatus(current) {
+		forbidden(w, r)
+		return
+	}
+
+	candidate := *existing
+	patch.ApplyTo(&candidate, time.Now())
+	if err := validate.Struct(&candidate); err != nil {
+		validationFailed(w, r, fieldErrors("", err)...)
+		return
+	}
+
+	user, err := h.repo.Patch(r.Context(), id, &patch, ifVersion)
+	if err != nil {
+		WriteStoreError(w, r, err, "User", "Failed to update user")
 		return
 	}
 
 	user.PasswordHash = ""
 
+	setETag(w, user.Version)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(user)
 }
 
+// ChangePassword verifies the current password and replaces it, signing
+// out every other session of the user
+func (h *UserHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	idStr := r.PathValue("id")
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		WriteError(w, r, http.StatusBadRequest, CodeInvalidID, "Invalid user ID")
+		return
+	}
+
+	var req changePasswordRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeDecodeError(w, r, err)
+		return
+	}
+
+	// Only the account owner knows the current password, so admins cannot
+	// rotate someone else's password through this endpoint either
+	if current := auth.UserFromContext(r.Context()); current == nil || current.ID != id {
+		forbidden(w, r)
+		return
+	}
+
+	user, err := h.repo.GetByID(r.Context(), id)
+	if err != nil {
+		WriteStoreError(w, r, err, "User", "Failed to get user")
+		return
+	}
+
+	ok, err := h.hasher.Verify(user.PasswordHash, req.CurrentPassword)
+	if err != nil && !errors.Is(err, auth.ErrInvalidHash) {
+		internalError(w, r, err, "Failed to verify password")
+		return
+	}
+	if !ok {
+		WriteError(w, r, http.StatusForbidden, CodeInvalidCredentials, "Current password is incorrect")
+		return
+	}
+
+	if err := h.policy.Check(req.NewPassword); err != nil {
+		validationFailed(w, r, fieldErrors("new_password", err)...)
+		return
+	}
+
+	hash, err := h.hasher.Hash(req.NewPassword)
+	if err != nil {
+		internalError(w, r, err, "Failed to update password")
+		return
+	}
+
+	if err := h.repo.UpdatePassword(r.Context(), id, hash); err != nil {
+		WriteStoreError(w, r, err, "User", "Failed to update password")
+		return
+	}
+
+	// Sign out every other session so that a leaked password or token
+	// stops working once the password is rotated
+	var keep string
+	if session := auth.SessionFromContext(r.Context()); session != nil {
+		keep = session.TokenHash
+	}
+	if err := h.sessions.DeleteByUser(r.Context(), id, keep); err != nil {
+		WriteStoreError(w, r, err, "Session", "Failed to revoke sessions")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
 // DeleteUser deletes a user by ID
 func (h *UserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
 	idStr := r.PathValue("id")
 	id, err := strconv.ParseInt(idStr, 10, 64)
 	if err != nil {
-		http.Error(w, "Invalid user ID", http.StatusBadRequest)
+		WriteError(w, r, http.StatusBadRequest, CodeInvalidID, "Invalid user ID")
+		return
+	}
+
+	ifVersion, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	if !auth.CanModifyUser(auth.UserFromContext(r.Context()), id) {
+		forbidden(w, r)
 		return
 	}
 
-	if err := h.repo.Delete(id); err != nil {
-		http.Error(w, "Failed to delete user", http.StatusInternalServerError)
+	if err := h.repo.Delete(r.Context(), id, ifVersion); err != nil {
+		WriteStoreError(w, r, err, "User", "Failed to delete user")
 		return
 	}
 
@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"bufio"
+	"database/sql"
+	"runtime"
+	"time"
+)
+
+// RegisterDBStats exposes the connection pool statistics of database
+func RegisterDBStats(r *Registry, database *sql.DB) {
+	r.register(&dbStatsCollector{database: database})
+}
+
+type dbStatsCollector struct {
+	database *sql.DB
+}
+
+func (c *dbStatsCollector) collect(w *bufio.Writer) {
+	stats := c.database.Stats()
+
+	gauges := []struct {
+		name, help string
+		value      int
+	}{
+		{"db_max_open_connections", "Maximum number of open connections to the database.", stats.MaxOpenConnections},
+		{"db_open_connections", "Number of established connections, both in use and idle.", stats.OpenConnections},
+		{"db_in_use_connections", "Number of connections currently in use.", stats.InUse},
+		{"db_idle_connections", "Number of idle connections.", stats.Idle},
+	}
+	for _, g := range gauges {
+		writeHeader(w, g.name, g.help, "gauge")
+		writeSample(w, g.name, nil, nil, float64(g.value))
+	}
+
+	counters := []struct {
+		name, help string
+		value      float64
+	}{
+		{"db_wait_count_total", "Total number of connections waited for.", float64(stats.WaitCount)},
+		{"db_wait_duration_seconds_total", "Total time blocked waiting for a new connection.", stats.WaitDuration.Seconds()},
+		{"db_max_idle_closed_total", "Total number of connections closed due to SetMaxIdleConns.", float64(stats.MaxIdleClosed)},
+		{"db_max_idle_time_closed_total", "Total number of connections closed due to SetConnMaxIdleTime.", float64(stats.MaxIdleTimeClosed)},
+		{"db_max_lifetime_closed_total", "Total number of connections closed due to SetConnMaxLifetime.", float64(stats.MaxLifetimeClosed)},
+	}
+	for _, c := range counters {
+		writeHeader(w, c.name, c.help, "counter")
+		writeSample(w, c.name, nil, nil, c.value)
+	}
+}
+
+// RegisterRuntime exposes Go runtime and process metrics
+func RegisterRuntime(r *Registry) {
+	r.register(&runtimeCollector{started: time.Now()})
+}
+
+type runtimeCollector struct {
+	started time.Time
+}
+
+func (c *runtimeCollector) collect(w *bufio.Writer) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	writeHeader(w, "go_info", "Information about the Go environment.", "gauge")
+	writeSample(w, "go_info", []string{"version"}, []string{runtime.Version()}, 1)
+
+	gauges := []struct {
+		name, help string
+		value      float64
+	}{
+		{"go_goroutines", "Number of goroutines that currently exist.", float64(runtime.NumGoroutine())},
+		{"go_memstats_alloc_bytes", "Number of bytes allocated and still in use.", float64(mem.Alloc)},
+		{"go_memstats_sys_bytes", "Number of bytes obtained from the system.", float64(mem.Sys)},
+		{"go_memstats_heap_inuse_bytes", "Number of heap bytes that are in use.", float64(mem.HeapInuse)},
+		{"go_memstats_heap_objects", "Number of allocated objects.", float64(mem.HeapObjects)},
+		{"process_start_time_seconds", "Start time of the process since unix epoch in seconds.", float64(c.started.UnixNano()) / 1e9},
+	}
+	for _, g := range gauges {
+		writeHeader(w, g.name, g.help, "gauge")
+		writeSample(w, g.name, nil, nil, g.value)
+	}
+
+	counters := []struct {
+		name, help string
+		value      float64
+	}{
+		{"go_memstats_alloc_bytes_total", "Total number of bytes allocated, even if freed.", float64(mem.TotalAlloc)},
+		{"go_gc_cycles_total", "Number of completed GC cycles.", float64(mem.NumGC)},
+		{"go_gc_pause_seconds_total", "Total time spent in GC stop-the-world pauses.", float64(mem.PauseTotalNs) / 1e9},
+	}
+	for _, c := range counters {
+		writeHeader(w, c.name, c.help, "counter")
+		writeSample(w, c.name, nil, nil, c.value)
+	}
+}
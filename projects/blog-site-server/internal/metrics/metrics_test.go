@@ -0,0 +1,153 @@
+package metrics
+
+import (
+	"math"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// scrape returns the exposition output of a registry
+func scrape(t *testing.T, r *Registry) string {
+	t.Helper()
+	var b strings.Builder
+	n, err := r.WriteTo(&b)
+	if err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+	if n != int64(b.Len()) {
+		t.Errorf("WriteTo reported %d bytes, wrote %d", n, b.Len())
+	}
+	return b.String()
+}
+
+func TestExposition(t *testing.T) {
+	tests := []struct {
+		name   string
+		record func(r *Registry)
+		want   string
+	}{
+		{
+			name: "counter without series",
+			record: func(r *Registry) {
+				r.NewCounterVec("requests_total", "Requests served.", "method")
+			},
+			want: "# HELP requests_total Requests served.\n" +
+				"# TYPE requests_total counter\n",
+		},
+		{
+			name: "counter series sorted by labels",
+			record: func(r *Registry) {
+				c := r.NewCounterVec("requests_total", "Requests served.", "method", "status")
+				c.With("POST", "201").Inc()
+				c.With("GET", "200").Add(2)
+				c.With("GET", "200").Add(-5)
+			},
+			want: "# HELP requests_total Requests served.\n" +
+				"# TYPE requests_total counter\n" +
+				`requests_total{method="GET",status="200"} 2` + "\n" +
+				`requests_total{method="POST",status="201"} 1` + "\n",
+		},
+		{
+			name: "gauge",
+			record: func(r *Registry) {
+				g := r.NewGauge("in_flight", "Requests in flight.")
+				g.Inc()
+				g.Inc()
+				g.Dec()
+				g.Add(0.5)
+			},
+			want: "# HELP in_flight Requests in flight.\n" +
+				"# TYPE in_flight gauge\n" +
+				"in_flight 1.5\n",
+		},
+		{
+			name: "histogram buckets are cumulative",
+			record: func(r *Registry) {
+				h := r.NewHistogramVec("duration_seconds", "Latency.", []float64{1, 0.1}, "route")
+				for _, v := range []float64{0.05, 0.5, 0.7, 3} {
+					h.With("/blogs").Observe(v)
+				}
+			},
+			want: "# HELP duration_seconds Latency.\n" +
+				"# TYPE duration_seconds histogram\n" +
+				`duration_seconds_bucket{route="/blogs",le="0.1"} 1` + "\n" +
+				`duration_seconds_bucket{route="/blogs",le="1"} 3` + "\n" +
+				`duration_seconds_bucket{route="/blogs",le="+Inf"} 4` + "\n" +
+				`duration_seconds_sum{route="/blogs"} 4.25` + "\n" +
+				`duration_seconds_count{route="/blogs"} 4` + "\n",
+		},
+		{
+			name: "escaping",
+			record: func(r *Registry) {
+				c := r.NewCounterVec("odd_total", "Back\\slash and\nnewline.", "value")
+				c.With("a\"b\\c\nd").Inc()
+			},
+			want: `# HELP odd_total Back\\slash and\nnewline.` + "\n" +
+				"# TYPE odd_total counter\n" +
+				`odd_total{value="a\"b\\c\nd"} 1` + "\n",
+		},
+		{
+			name: "families in registration order",
+			record: func(r *Registry) {
+				r.NewGauge("b", "B.")
+				r.NewGauge("a", "A.")
+			},
+			want: "# HELP b B.\n# TYPE b gauge\nb 0\n" +
+				"# HELP a A.\n# TYPE a gauge\na 0\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := NewRegistry()
+			tt.record(r)
+			if got := scrape(t, r); got != tt.want {
+				t.Errorf("exposition:\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatFloat(t *testing.T) {
+	tests := []struct {
+		value float64
+		want  string
+	}{
+		{0, "0"},
+		{42, "42"},
+		{0.005, "0.005"},
+		{1e21, "1e+21"},
+		{math.Inf(1), "+Inf"},
+		{math.Inf(-1), "-Inf"},
+		{math.NaN(), "NaN"},
+	}
+	for _, tt := range tests {
+		if got := formatFloat(tt.value); got != tt.want {
+			t.Errorf("formatFloat(%v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestWrongLabelCountPanics(t *testing.T) {
+	c := NewRegistry().NewCounterVec("requests_total", "Requests served.", "method", "status")
+	defer func() {
+		if recover() == nil {
+			t.Error("With with too few label values did not panic")
+		}
+	}()
+	c.With("GET")
+}
+
+func TestHandler(t *testing.T) {
+	r := NewRegistry()
+	r.NewGauge("up", "Whether the server is up.").Inc()
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if got := rec.Header().Get("Content-Type"); got != contentType {
+		t.Errorf("Content-Type = %q, want %q", got, contentType)
+	}
+	if !strings.Contains(rec.Body.String(), "\nup 1\n") {
+		t.Errorf("body does not contain the gauge:\n%s", rec.Body.String())
+	}
+}
@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"blog-app/internal/auth"
+	"blog-app/internal/handlers"
+	"blog-app/internal/repository"
+	"database/sql"
+	"net/http"
+	"strings"
+)
+
+// Authenticate resolves the bearer token on the request into the current
+// user and stores it in the request context. Requests without an
+// Authorization header pass through anonymously; a bad token is rejected
+// and counted against the client IP by limiter, which turns away clients
+// that keep guessing before their tokens are looked up.
+func Authenticate(sessions repository.SessionStore, users repository.UserStore, limiter *RateLimiter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			header := r.Header.Get("Authorization")
+			if header == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !limiter.allowAuthAttempt(w, r) {
+				return
+			}
+			rejected := func(message string) {
+				limiter.failedAuth(r)
+				unauthorized(w, r, message)
+			}
+
+			scheme, token, ok := strings.Cut(header, " ")
+			if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+				rejected("Malformed Authorization header")
+				return
+			}
+
+			session, err := sessions.GetByTokenHash(r.Context(), auth.HashToken(token))
+			if err != nil {
+				if err == sql.ErrNoRows {
+					rejected("Invalid or expired token")
+				} else {
+					handlers.WriteStoreError(w, r, err, "Session", "Failed to authenticate")
+				}
+				return
+			}
+
+			user, err := users.GetByID(r.Context(), session.UserID)
+			if err != nil {
+				if err == sql.ErrNoRows {
+					rejected("Invalid or expired token")
+				} else {
+					handlers.WriteStoreError(w, r, err, "Session", "Failed to authenticate")
+				}
+				return
+			}
+			user.PasswordHash = ""
+			logUser(r.Context(), user.ID)
+
+			next.ServeHTTP(w, r.WithContext(auth.WithUser(r.Context(), user, session)))
+		})
+	}
+}
+
+// RequireAuth rejects requests that are not made by an authenticated,
+// active user
+func RequireAuth(next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user := auth.UserFromContext(r.Context())
+		if user == nil {
+			unauthorized(w, r, "Authentication required")
+			return
+		}
+		if !user.IsActive {
+			handlers.WriteError(w, r, http.StatusForbidden, handlers.CodeAccountInactive, "Account is deactivated")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// unauthorized writes a 401 with a bearer challenge
+func unauthorized(w http.ResponseWriter, r *http.Request, message string) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="blog-app"`)
+	handlers.WriteError(w, r, http.StatusUnauthorized, handlers.CodeUnauthorized, message)
+}
@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"blog-app/internal/handlers"
+	"net/http"
+)
+
+// LimitBody caps request bodies at maxBytes. Bodies declared larger are
+// rejected up front; reading past the limit otherwise fails with an
+// *http.MaxBytesError, which handlers report as 413 as well.
+func LimitBody(maxBytes int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.ContentLength > maxBytes {
+				handlers.WriteError(w, r, http.StatusRequestEntityTooLarge, handlers.CodeBodyTooLarge, handlers.BodyTooLargeDetail(maxBytes))
+				return
+			}
+			r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
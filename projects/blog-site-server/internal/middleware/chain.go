@@ -0,0 +1,17 @@
+package middleware
+
+import (
+	"net/http"
+)
+
+// Middleware wraps a handler with extra behaviour
+type Middleware func(http.Handler) http.Handler
+
+// Chain wraps handler with the middlewares, the first being outermost, so
+// a request passes through them in the order they are listed
+func Chain(handler http.Handler, middlewares ...Middleware) http.Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// accessEntry collects details about a request that only inner handlers
+// learn, such as the authenticated user
+type accessEntry struct {
+	userID int64
+}
+
+type accessEntryKey struct{}
+
+// AccessLog writes one structured line per request once it has been
+// served. It must run inside RequestID so the line carries the request ID.
+// A request aborted by a panic is logged as a 500 before the panic goes on.
+func AccessLog(mux *http.ServeMux) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			entry := &accessEntry{}
+			start := time.Now()
+			recorder := newResponseRecorder(w)
+			defer func() {
+				p := recover()
+				status := recorder.status
+				if p != nil {
+					status = http.StatusInternalServerError
+				}
+
+				attrs := []slog.Attr{
+					slog.String("method", r.Method),
+					slog.String("route", routePattern(mux, r)),
+					slog.String("path", r.URL.Path),
+					slog.Int("status", status),
+					slog.Int64("bytes", recorder.bytes),
+					slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+				}
+				if entry.userID != 0 {
+					attrs = append(attrs, slog.Int64("user_id", entry.userID))
+				}
+				if p != nil {
+					attrs = append(attrs, slog.Bool("aborted", true))
+				}
+
+				level := slog.LevelInfo
+				if status >= http.StatusInternalServerError {
+					level = slog.LevelWarn
+				}
+				slog.LogAttrs(r.Context(), level, "request", attrs...)
+
+				if p != nil {
+					panic(p)
+				}
+			}()
+			next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), accessEntryKey{}, entry)))
+		})
+	}
+}
+
+// logUser records the authenticated user for the access log
+func logUser(ctx context.Context, userID int64) {
+	if entry, ok := ctx.Value(accessEntryKey{}).(*accessEntry); ok {
+		entry.userID = userID
+	}
+}
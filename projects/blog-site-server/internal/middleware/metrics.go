@@ -0,0 +1,88 @@
+package middleware
+
+import (
+	"blog-app/internal/metrics"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// unmatchedRoute labels requests that no route pattern matched, so that
+// probing random paths cannot create unbounded series
+const unmatchedRoute = "unmatched"
+
+// otherMethod labels requests with a method outside knownMethods, which
+// clients could otherwise invent without limit
+const otherMethod = "other"
+
+var knownMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodOptions: true,
+}
+
+// Instrument records request counts, latencies and the number of requests
+// in flight. Requests are labelled with the mux pattern that serves them,
+// e.g. /blogs/{id}, rather than the raw path, and with their method if it
+// is a standard one.
+func Instrument(registry *metrics.Registry, mux *http.ServeMux) func(http.Handler) http.Handler {
+	requests := registry.NewCounterVec("http_requests_total",
+		"Total number of HTTP requests by route and status code.", "method", "route", "status")
+	durations := registry.NewHistogramVec("http_request_duration_seconds",
+		"HTTP request latency by route.", metrics.DefaultBuckets, "method", "route")
+	inFlight := registry.NewGauge("http_requests_in_flight",
+		"Number of HTTP requests currently being served.")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			method := methodLabel(r.Method)
+			route := routePattern(mux, r)
+
+			inFlight.Inc()
+			defer inFlight.Dec()
+
+			start := time.Now()
+			recorder := newResponseRecorder(w)
+			defer func() {
+				// A request aborted by a panic counts as a 500
+				p := recover()
+				status := recorder.status
+				if p != nil {
+					status = http.StatusInternalServerError
+				}
+				durations.With(method, route).Observe(time.Since(start).Seconds())
+				requests.With(method, route, strconv.Itoa(status)).Inc()
+				if p != nil {
+					panic(p)
+				}
+			}()
+			next.ServeHTTP(recorder, r)
+		})
+	}
+}
+
+// methodLabel returns the method label for a request method
+func methodLabel(method string) string {
+	if knownMethods[method] {
+		return method
+	}
+	return otherMethod
+}
+
+// routePattern returns the path of the mux pattern matching r
+func routePattern(mux *http.ServeMux, r *http.Request) string {
+	_, pattern := mux.Handler(r)
+	if pattern == "" {
+		return unmatchedRoute
+	}
+	// Patterns may be prefixed with their method, e.g. "GET /blogs"
+	if _, path, ok := strings.Cut(pattern, " "); ok {
+		return path
+	}
+	return pattern
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"blog-app/internal/metrics"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestInstrument(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /blogs/{id}", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("POST /blogs", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+	})
+	mux.HandleFunc("/any", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("GET /panic", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		panic("boom")
+	})
+
+	registry := metrics.NewRegistry()
+	handler := Instrument(registry, mux)(mux)
+
+	tests := []struct {
+		method, path string
+		want         string
+	}{
+		{"GET", "/blogs/1", `http_requests_total{method="GET",route="/blogs/{id}",status="200"}`},
+		{"POST", "/blogs", `http_requests_total{method="POST",route="/blogs",status="201"}`},
+		{"GET", "/nowhere", `http_requests_total{method="GET",route="unmatched",status="404"}`},
+		{"FOO", "/any", `http_requests_total{method="other",route="/any",status="200"}`},
+		{"BAR", "/any", `http_requests_total{method="other",route="/any",status="200"}`},
+		{"GET", "/panic", `http_requests_total{method="GET",route="/panic",status="500"}`},
+	}
+	for _, tt := range tests {
+		func() {
+			defer func() {
+				if p := recover(); p != nil && tt.path != "/panic" {
+					t.Errorf("%s %s panicked: %v", tt.method, tt.path, p)
+				}
+			}()
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(tt.method, tt.path, nil))
+			if tt.path == "/panic" {
+				t.Error("the panic was not passed on")
+			}
+		}()
+	}
+
+	var b strings.Builder
+	registry.WriteTo(&b)
+	out := b.String()
+	for _, tt := range tests {
+		count := " 1\n"
+		if tt.method == "FOO" || tt.method == "BAR" {
+			count = " 2\n"
+		}
+		if !strings.Contains(out, tt.want+count) {
+			t.Errorf("%s %s: missing %s%s", tt.method, tt.path, tt.want, count)
+		}
+	}
+	if strings.Contains(out, "FOO") || strings.Contains(out, "BAR") {
+		t.Errorf("non-standard methods leaked into labels:\n%s", out)
+	}
+	if !strings.Contains(out, "\nhttp_requests_in_flight 0\n") {
+		t.Errorf("requests still in flight:\n%s", out)
+	}
+}
@@ -0,0 +1,174 @@
+package middleware
+
+import (
+	"blog-app/internal/auth"
+	"blog-app/internal/config"
+	"blog-app/internal/handlers"
+	"blog-app/internal/ratelimit"
+	"fmt"
+	"math"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// failedAuthGroup is charged for requests with a bad bearer token, since
+// guessing tokens is as much an attack on credentials as guessing passwords
+const failedAuthGroup = "auth"
+
+// RateLimiter enforces the per-client limits of each route group
+type RateLimiter struct {
+	groups  map[string]*ratelimit.Limiter
+	trusted []netip.Prefix
+}
+
+// NewRateLimiter creates the buckets for the groups enabled in cfg
+func NewRateLimiter(cfg config.RateLimit) *RateLimiter {
+	groups := make(map[string]*ratelimit.Limiter)
+	for name, limit := range cfg.Groups() {
+		if limit.Requests > 0 {
+			groups[name] = ratelimit.New(limit, cfg.MaxClients)
+		}
+	}
+	// Already checked when the configuration was loaded
+	trusted, _ := cfg.TrustedProxyPrefixes()
+	return &RateLimiter{groups: groups, trusted: trusted}
+}
+
+// Limit limits how often each client may call the routes of a group.
+// group names the group of a request from its method and route pattern,
+// or returns "" for routes that are never limited. Clients are identified
+// by user when authenticated and by IP otherwise, so Limit must run after
+// Authenticate.
+func (l *RateLimiter) Limit(mux *http.ServeMux, group func(method, route string) string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			limiter, ok := l.groups[group(r.Method, routePattern(mux, r))]
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if !writeDecision(w, r, limiter.Allow(l.clientKey(r), time.Now())) {
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// allowAuthAttempt reports whether the client IP may still try to
+// authenticate, writing a 429 otherwise. It takes no token, so lookups of
+// valid tokens are never limited here.
+func (l *RateLimiter) allowAuthAttempt(w http.ResponseWriter, r *http.Request) bool {
+	limiter, ok := l.groups[failedAuthGroup]
+	if !ok {
+		return true
+	}
+	decision := limiter.Peek(l.ipKey(r), time.Now())
+	return decision.Allowed || writeDecision(w, r, decision)
+}
+
+// failedAuth counts a rejected bearer token against the client IP
+func (l *RateLimiter) failedAuth(r *http.Request) {
+	if limiter, ok := l.groups[failedAuthGroup]; ok {
+		limiter.Allow(l.ipKey(r), time.Now())
+	}
+}
+
+// writeDecision sets the RateLimit headers and, when the request is not
+// allowed, writes a 429. It reports whether the request may proceed.
+func writeDecision(w http.ResponseWriter, r *http.Request, decision ratelimit.Decision) bool {
+	header := w.Header()
+	header.Set("RateLimit-Limit", strconv.Itoa(decision.Limit))
+	header.Set("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+	header.Set("RateLimit-Reset", seconds(decision.Reset))
+	if decision.Allowed {
+		return true
+	}
+
+	retryAfter := seconds(decision.RetryAfter)
+	header.Set("Retry-After", retryAfter)
+	handlers.WriteError(w, r, http.StatusTooManyRequests, handlers.CodeRateLimited,
+		fmt.Sprintf("Too many requests, retry in %s seconds", retryAfter))
+	return false
+}
+
+// seconds formats d as whole seconds, rounded up so clients never retry
+// too early
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// clientKey identifies the client a request is counted against
+func (l *RateLimiter) clientKey(r *http.Request) string {
+	if user := auth.UserFromContext(r.Context()); user != nil {
+		return "user:" + strconv.FormatInt(user.ID, 10)
+	}
+	return l.ipKey(r)
+}
+
+// ipKey identifies the client address of a request
+func (l *RateLimiter) ipKey(r *http.Request) string {
+	addr, ok := clientIP(r, l.trusted)
+	if !ok {
+		return "addr:" + r.RemoteAddr
+	}
+	// An IPv6 client usually controls a whole /64
+	if addr.Is6() {
+		prefix, _ := addr.WithZone("").Prefix(64)
+		return "ip:" + prefix.String()
+	}
+	return "ip:" + addr.String()
+}
+
+// clientIP returns the address the request came from. X-Forwarded-For is
+// only believed when the peer is a trusted proxy, and is read from the
+// right, skipping further trusted proxies: entries left of the first
+// untrusted one may have been forged by the client.
+func clientIP(r *http.Request, trusted []netip.Prefix) (netip.Addr, bool) {
+	addr, ok := parseIP(r.RemoteAddr)
+	if !ok || !isTrusted(addr, trusted) {
+		return addr, ok
+	}
+
+	var hops []string
+	for _, value := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, ok := parseIP(strings.TrimSpace(hops[i]))
+		if !ok {
+			// Nothing further left can be trusted
+			break
+		}
+		addr = hop
+		if !isTrusted(hop, trusted) {
+			break
+		}
+	}
+	return addr, true
+}
+
+// parseIP accepts an address with or without a port
+func parseIP(s string) (netip.Addr, bool) {
+	if addrPort, err := netip.ParseAddrPort(s); err == nil {
+		return addrPort.Addr().Unmap(), true
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
+
+func isTrusted(addr netip.Addr, trusted []netip.Prefix) bool {
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
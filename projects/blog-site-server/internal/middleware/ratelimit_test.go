@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"blog-app/internal/config"
+	"blog-app/internal/ratelimit"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("192.168.1.1/32"),
+		netip.MustParsePrefix("fd00::/8"),
+	}
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		want       string
+		wantOK     bool
+	}{
+		{"direct client", "203.0.113.7:5000", nil, "203.0.113.7", true},
+		{"untrusted peer cannot forward", "203.0.113.7:5000", []string{"198.51.100.1"}, "203.0.113.7", true},
+		{"trusted proxy without header", "10.1.2.3:80", nil, "10.1.2.3", true},
+		{"trusted proxy", "10.1.2.3:80", []string{"198.51.100.1"}, "198.51.100.1", true},
+		{"rightmost untrusted hop wins", "10.1.2.3:80", []string{"1.1.1.1, 198.51.100.1"}, "198.51.100.1", true},
+		{"trusted hops are skipped", "10.1.2.3:80", []string{"198.51.100.1, 192.168.1.1, 10.9.9.9"}, "198.51.100.1", true},
+		{"multiple headers", "10.1.2.3:80", []string{"1.1.1.1", "198.51.100.1, 10.9.9.9"}, "198.51.100.1", true},
+		{"all hops trusted", "10.1.2.3:80", []string{"10.2.2.2, 192.168.1.1"}, "10.2.2.2", true},
+		{"garbage stops the walk", "10.1.2.3:80", []string{"1.1.1.1, junk, 10.9.9.9"}, "10.9.9.9", true},
+		{"hop with port", "10.1.2.3:80", []string{"198.51.100.1:4711"}, "198.51.100.1", true},
+		{"mapped IPv4 peer", "[::ffff:10.1.2.3]:80", []string{"198.51.100.1"}, "198.51.100.1", true},
+		{"IPv6 proxy and client", "[fd00::1]:80", []string{"2001:db8::5"}, "2001:db8::5", true},
+		{"unparseable peer", "pipe", []string{"198.51.100.1"}, "invalid IP", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwarded {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+			got, ok := clientIP(r, trusted)
+			if ok != tt.wantOK || got.String() != tt.want {
+				t.Errorf("clientIP = %s, %v; want %s, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestIPKey(t *testing.T) {
+	l := NewRateLimiter(config.RateLimit{})
+	tests := []struct {
+		remoteAddr string
+		want       string
+	}{
+		{"203.0.113.7:5000", "ip:203.0.113.7"},
+		{"[2001:db8:1:2:3:4:5:6]:443", "ip:2001:db8:1:2::/64"},
+		{"[fe80::1%eth0]:443", "ip:fe80::/64"},
+		{"pipe", "addr:pipe"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tt.remoteAddr
+		if got := l.ipKey(r); got != tt.want {
+			t.Errorf("ipKey(%s) = %q, want %q", tt.remoteAddr, got, tt.want)
+		}
+	}
+}
+
+func TestFailedAuthLimit(t *testing.T) {
+	l := NewRateLimiter(config.RateLimit{
+		Auth:       ratelimit.Limit{Requests: 2, Period: time.Hour},
+		MaxClients: 10,
+	})
+	request := func(remoteAddr string) *http.Request {
+		r := httptest.NewRequest("GET", "/blogs", nil)
+		r.RemoteAddr = remoteAddr
+		return r
+	}
+	attempt := func(remoteAddr string) int {
+		w := httptest.NewRecorder()
+		if !l.allowAuthAttempt(w, request(remoteAddr)) {
+			return w.Code
+		}
+		return 0
+	}
+
+	// Attempts cost nothing by themselves
+	for range 5 {
+		if code := attempt("203.0.113.7:1"); code != 0 {
+			t.Fatalf("attempt before any failure got %d, want it allowed", code)
+		}
+	}
+
+	l.failedAuth(request("203.0.113.7:1"))
+	l.failedAuth(request("203.0.113.7:2"))
+	if code := attempt("203.0.113.7:3"); code != http.StatusTooManyRequests {
+		t.Errorf("attempt after the failures got %d, want 429", code)
+	}
+	if code := attempt("198.51.100.1:1"); code != 0 {
+		t.Errorf("attempt from another client got %d, want it allowed", code)
+	}
+}
+
+func TestFailedAuthWithoutAuthGroup(t *testing.T) {
+	l := NewRateLimiter(config.RateLimit{MaxClients: 10})
+	r := httptest.NewRequest("GET", "/blogs", nil)
+	for range 5 {
+		l.failedAuth(r)
+	}
+	if !l.allowAuthAttempt(httptest.NewRecorder(), r) {
+		t.Error("attempt was limited although the auth group is disabled")
+	}
+}
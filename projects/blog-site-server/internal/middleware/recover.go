@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"blog-app/internal/handlers"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+)
+
+// Recover turns a panicking handler into a logged 500 instead of a dropped
+// connection. If the response has already started it can only be cut short.
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder := newResponseRecorder(w)
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			// ErrAbortHandler is the sanctioned way to abort a response
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+
+			slog.ErrorContext(r.Context(), "Panic while serving request",
+				"panic", p, "stack", string(debug.Stack()))
+			if recorder.wroteHeader {
+				panic(http.ErrAbortHandler)
+			}
+			handlers.WriteError(recorder, r, http.StatusInternalServerError, handlers.CodeInternal, "Internal server error")
+		}()
+		next.ServeHTTP(recorder, r)
+	})
+}
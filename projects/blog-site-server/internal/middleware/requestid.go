@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"blog-app/internal/requestid"
+	"net/http"
+)
+
+// RequestID tags every request with an ID, reusing the client's X-Request-ID
+// when it is well formed, and echoes it in the response
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestid.Header)
+		if !requestid.Valid(id) {
+			id = requestid.New()
+		}
+		w.Header().Set(requestid.Header, id)
+		next.ServeHTTP(w, r.WithContext(requestid.WithID(r.Context(), id)))
+	})
+}
@@ -7,10 +7,23 @@ import (
 // Blog model
 type Blog struct {
 	ID         int64      `json:"id"`
-	Title      string     `json:"title"`
-	Content    string     `json:"content"`
-	CoverImage string     `json:"cover_image"`
+	Title      string     `json:"title" validate:"required,max=200"`
+	Content    string     `json:"content" validate:"max=100000"`
+	CoverImage string     `json:"cover_image" validate:"url,max=2048"`
 	AuthorID   int64      `json:"author_id"`
+	Tags       []string   `json:"tags"`
 	CreatedAt  time.Time  `json:"created_at"`
 	UpdatedAt  *time.Time `json:"updated_at"`
+
+	// Version is bumped by every write and served as the ETag
+	Version int64 `json:"-"`
+}
+
+// BlogSearchResult is a blog post matched by full-text search. The
+// highlights are HTML-escaped with matches wrapped in <mark> tags.
+type BlogSearchResult struct {
+	Blog
+	Rank           float64 `json:"rank"`
+	TitleHighlight string  `json:"title_highlight"`
+	Snippet        string  `json:"snippet"`
 }
@@ -4,12 +4,27 @@ import (
 	"time"
 )
 
+// DeletedCommentContent replaces the content of a deleted comment that is
+// kept because it still has replies
+const DeletedCommentContent = "[deleted]"
+
 // Comment model
 type Comment struct {
 	ID        int64     `json:"id"`
-	PostID    int64     `json:"post_id"`
+	PostID    int64     `json:"post_id" validate:"required"`
 	UserID    int64     `json:"user_id"`
-	Content   string    `json:"content"`
+	ParentID  *int64    `json:"parent_id"`
+	Content   string    `json:"content" validate:"required,max=5000"`
+	Deleted   bool      `json:"deleted"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	// Version is bumped by every write and served as the ETag
+	Version int64 `json:"-"`
+
+	// Replies and HasMoreReplies are only populated in tree listings.
+	// HasMoreReplies marks comments whose replies were cut off by the
+	// maximum depth.
+	Replies        []*Comment `json:"replies,omitempty"`
+	HasMoreReplies bool       `json:"has_more_replies,omitempty"`
 }
@@ -0,0 +1,139 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Optional is a field of a JSON Merge Patch (RFC 7396). It distinguishes a
+// member that is absent (Set is false) from one that is explicitly null
+// (Set and Null) and one carrying a value.
+type Optional[T any] struct {
+	Set   bool
+	Null  bool
+	Value T
+}
+
+// UnmarshalJSON is only invoked for members present in the document
+func (o *Optional[T]) UnmarshalJSON(data []byte) error {
+	o.Set = true
+	if string(data) == "null" {
+		o.Null = true
+		var zero T
+		o.Value = zero
+		return nil
+	}
+	return json.Unmarshal(data, &o.Value)
+}
+
+// NullError reports a null assigned to a field that cannot be cleared
+type NullError struct {
+	Field string
+}
+
+func (e *NullError) Error() string {
+	return fmt.Sprintf("%s cannot be null", e.Field)
+}
+
+// requireValue fails when a non-nullable field is explicitly null
+func requireValue[T any](field string, o Optional[T]) error {
+	if o.Null {
+		return &NullError{Field: field}
+	}
+	return nil
+}
+
+// apply copies a set field onto its target; null clears it to the zero value
+func apply[T any](o Optional[T], target *T) {
+	if o.Set {
+		*target = o.Value
+	}
+}
+
+// BlogPatch is a merge patch for a blog post. Content, cover_image and
+// tags may be cleared with null; title may not. Tags replace the whole set.
+type BlogPatch struct {
+	Title      Optional[string]   `json:"title"`
+	Content    Optional[string]   `json:"content"`
+	CoverImage Optional[string]   `json:"cover_image"`
+	Tags       Optional[[]string] `json:"tags"`
+}
+
+// Validate rejects nulls on required fields
+func (p *BlogPatch) Validate() error {
+	return requireValue("title", p.Title)
+}
+
+// Empty reports whether the patch touches no fields
+func (p *BlogPatch) Empty() bool {
+	return !p.Title.Set && !p.Content.Set && !p.CoverImage.Set && !p.Tags.Set
+}
+
+// ApplyTo merges the patch into blog
+func (p *BlogPatch) ApplyTo(blog *Blog, now time.Time) {
+	apply(p.Title, &blog.Title)
+	apply(p.Content, &blog.Content)
+	apply(p.CoverImage, &blog.CoverImage)
+	apply(p.Tags, &blog.Tags)
+	blog.UpdatedAt = &now
+}
+
+// UserPatch is a merge patch for a user. Name, bio and avatar_url may be
+// cleared with null; the other fields may not.
+type UserPatch struct {
+	FullName  Optional[string] `json:"name"`
+	Email     Optional[string] `json:"email"`
+	Role      Optional[string] `json:"role"`
+	Bio       Optional[string] `json:"bio"`
+	AvatarURL Optional[string] `json:"avatar_url"`
+	IsActive  Optional[bool]   `json:"is_active"`
+}
+
+// Validate rejects nulls on required fields
+func (p *UserPatch) Validate() error {
+	if err := requireValue("email", p.Email); err != nil {
+		return err
+	}
+	if err := requireValue("role", p.Role); err != nil {
+		return err
+	}
+	return requireValue("is_active", p.IsActive)
+}
+
+// Empty reports whether the patch touches no fields
+func (p *UserPatch) Empty() bool {
+	return !p.FullName.Set && !p.Email.Set && !p.Role.Set && !p.Bio.Set && !p.AvatarURL.Set && !p.IsActive.Set
+}
+
+// ApplyTo merges the patch into user
+func (p *UserPatch) ApplyTo(user *User, now time.Time) {
+	apply(p.FullName, &user.FullName)
+	apply(p.Email, &user.Email)
+	apply(p.Role, &user.Role)
+	apply(p.Bio, &user.Bio)
+	apply(p.AvatarURL, &user.AvatarURL)
+	apply(p.IsActive, &user.IsActive)
+	user.UpdatedAt = now
+}
+
+// CommentPatch is a merge patch for a comment
+type CommentPatch struct {
+	Content Optional[string] `json:"content"`
+}
+
+// Validate rejects nulls on required fields
+func (p *CommentPatch) Validate() error {
+	return requireValue("content", p.Content)
+}
+
+// Empty reports whether the patch touches no fields
+func (p *CommentPatch) Empty() bool {
+	return !p.Content.Set
+}
+
+// ApplyTo merges the patch into comment
+func (p *CommentPatch) ApplyTo(comment *Comment, now time.Time) {
+	apply(p.Content, &comment.Content)
+	comment.UpdatedAt = now
+}
@@ -0,0 +1,14 @@
+package models
+
+import (
+	"time"
+)
+
+// Session model. Only a hash of the bearer token is stored so that a leaked
+// sessions table cannot be replayed.
+type Session struct {
+	TokenHash string    `json:"-"`
+	UserID    int64     `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// MaxTagsPerBlog caps the number of tags on one blog post
+	MaxTagsPerBlog = 10
+	// MaxTagLength caps the length of a tag slug in characters
+	MaxTagLength = 50
+)
+
+// Tag labels blog posts. A tag is identified by its slug, so names that
+// only differ in case or whitespace denote the same tag.
+type Tag struct {
+	ID        int64     `json:"id"`
+	Slug      string    `json:"slug"`
+	CreatedAt time.Time `json:"created_at"`
+	// PostCount is the number of blog posts carrying the tag
+	PostCount int64 `json:"post_count"`
+}
+
+// TagSlug normalizes a tag name: surrounding whitespace is dropped, the
+// rest is lower-cased and runs of inner whitespace become one hyphen
+func TagSlug(name string) string {
+	return strings.Join(strings.Fields(strings.ToLower(name)), "-")
+}
+
+// ValidateTagSlug checks a normalized slug. Slugs appear in URLs, so they
+// are limited to letters, digits and a few punctuation marks found in
+// technology names such as c++, c# or node.js.
+func ValidateTagSlug(slug string) error {
+	if slug == "" {
+		return fmt.Errorf("must not contain blank tags")
+	}
+	if utf8.RuneCountInString(slug) > MaxTagLength {
+		return fmt.Errorf("tag %q must be at most %d characters", slug, MaxTagLength)
+	}
+	for i, c := range slug {
+		if unicode.IsLetter(c) || unicode.IsDigit(c) {
+			continue
+		}
+		if i > 0 && strings.ContainsRune("-+#.", c) {
+			continue
+		}
+		return fmt.Errorf("tag %q may only contain letters, digits and - + # . after the first character", slug)
+	}
+	return nil
+}
+
+// NormalizeTags turns tag names into their slugs, dropping duplicates.
+// The result is sorted and never nil.
+func NormalizeTags(names []string) ([]string, error) {
+	slugs := make([]string, 0, len(names))
+	for _, name := range names {
+		slug := TagSlug(name)
+		if err := ValidateTagSlug(slug); err != nil {
+			return nil, err
+		}
+		slugs = append(slugs, slug)
+	}
+	slices.Sort(slugs)
+	slugs = slices.Compact(slugs)
+	if len(slugs) > MaxTagsPerBlog {
+		return nil, fmt.Errorf("at most %d tags are allowed", MaxTagsPerBlog)
+	}
+	return slugs, nil
+}
@@ -7,14 +7,17 @@ import (
 // User model
 type User struct {
 	ID           int64     `json:"id"`
-	Username     string    `json:"username"`
-	FullName     string    `json:"name"`
-	Email        string    `json:"email"`
-	Role         string    `json:"role"`
+	Username     string    `json:"username" validate:"required,min=3,max=50"`
+	FullName     string    `json:"name" validate:"max=100"`
+	Email        string    `json:"email" validate:"required,email,max=254"`
+	Role         string    `json:"role" validate:"required,oneof=admin editor author reader"`
 	PasswordHash string    `json:"-"`
-	Bio          string    `json:"bio"`
-	AvatarURL    string    `json:"avatar_url"`
+	Bio          string    `json:"bio" validate:"max=1000"`
+	AvatarURL    string    `json:"avatar_url" validate:"url,max=2048"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 	IsActive     bool      `json:"is_active"`
+
+	// Version is bumped by every write and served as the ETag
+	Version int64 `json:"-"`
 }
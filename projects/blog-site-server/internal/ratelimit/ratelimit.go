@@ -0,0 +1,139 @@
+package ratelimit
+
+import (
+	"container/list"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit allows Requests per Period on average, in bursts of up to Requests
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// Decision is the outcome of one Allow call
+type Decision struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// RetryAfter is how long until a request would be allowed again; zero
+	// when this one was
+	RetryAfter time.Duration
+	// Reset is how long until the bucket has fully refilled
+	Reset time.Duration
+}
+
+// Limiter keeps one token bucket per key. A bucket left alone for a whole
+// period has refilled and is indistinguishable from a new one, so such
+// buckets are swept away once per period. At most maxKeys buckets are
+// kept; beyond that the least recently used one is evicted.
+type Limiter struct {
+	limit   Limit
+	rate    float64 // tokens per second
+	burst   float64
+	maxKeys int
+
+	mu      sync.Mutex
+	buckets map[string]*list.Element
+	// recent orders the buckets from most to least recently used
+	recent    *list.List
+	lastSweep time.Time
+}
+
+type bucket struct {
+	key     string
+	tokens  float64
+	updated time.Time
+}
+
+// New creates a limiter; limit must allow at least one request per period
+func New(limit Limit, maxKeys int) *Limiter {
+	return &Limiter{
+		limit:   limit,
+		rate:    float64(limit.Requests) / limit.Period.Seconds(),
+		burst:   float64(limit.Requests),
+		maxKeys: maxKeys,
+		buckets: make(map[string]*list.Element),
+		recent:  list.New(),
+	}
+}
+
+// Allow takes a token from the bucket of key if one is available
+func (l *Limiter) Allow(key string, now time.Time) Decision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastSweep) >= l.limit.Period {
+		l.sweep(now)
+	}
+
+	var b *bucket
+	if element, ok := l.buckets[key]; ok {
+		l.recent.MoveToFront(element)
+		b = element.Value.(*bucket)
+		b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.updated).Seconds()*l.rate)
+		b.updated = now
+	} else {
+		if len(l.buckets) >= l.maxKeys {
+			l.remove(l.recent.Back())
+		}
+		b = &bucket{key: key, tokens: l.burst, updated: now}
+		l.buckets[key] = l.recent.PushFront(b)
+	}
+
+	decision := Decision{Limit: l.limit.Requests}
+	if b.tokens >= 1 {
+		b.tokens--
+		decision.Allowed = true
+	} else {
+		decision.RetryAfter = l.duration(1 - b.tokens)
+	}
+	decision.Remaining = int(b.tokens)
+	decision.Reset = l.duration(l.burst - b.tokens)
+	return decision
+}
+
+// Peek reports what Allow would decide for key without taking a token
+func (l *Limiter) Peek(key string, now time.Time) Decision {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	tokens := l.burst
+	if element, ok := l.buckets[key]; ok {
+		b := element.Value.(*bucket)
+		tokens = math.Min(l.burst, b.tokens+now.Sub(b.updated).Seconds()*l.rate)
+	}
+
+	decision := Decision{Limit: l.limit.Requests, Allowed: tokens >= 1}
+	if !decision.Allowed {
+		decision.RetryAfter = l.duration(1 - tokens)
+	}
+	decision.Remaining = int(tokens)
+	decision.Reset = l.duration(l.burst - tokens)
+	return decision
+}
+
+// duration returns how long it takes to refill the given number of tokens
+func (l *Limiter) duration(tokens float64) time.Duration {
+	return time.Duration(tokens / l.rate * float64(time.Second))
+}
+
+// sweep drops the buckets idle for at least a period, starting from the
+// least recently used and stopping at the first one still in use
+func (l *Limiter) sweep(now time.Time) {
+	for element := l.recent.Back(); element != nil; element = l.recent.Back() {
+		if now.Sub(element.Value.(*bucket).updated) < l.limit.Period {
+			break
+		}
+		l.remove(element)
+	}
+	l.lastSweep = now
+}
+
+// remove drops the bucket held by element
+func (l *Limiter) remove(element *list.Element) {
+	delete(l.buckets, element.Value.(*bucket).key)
+	l.recent.Remove(element)
+}
@@ -0,0 +1,177 @@
+package ratelimit
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// sameDecision compares decisions, allowing for float rounding in durations
+func sameDecision(a, b Decision) bool {
+	near := func(x, y time.Duration) bool {
+		return (x - y).Abs() < time.Millisecond
+	}
+	return a.Allowed == b.Allowed && a.Limit == b.Limit && a.Remaining == b.Remaining &&
+		near(a.RetryAfter, b.RetryAfter) && near(a.Reset, b.Reset)
+}
+
+func TestAllow(t *testing.T) {
+	// 2 requests per 10s: one token every 5s
+	limit := Limit{Requests: 2, Period: 10 * time.Second}
+
+	type step struct {
+		at   time.Duration
+		want Decision
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "burst then reject",
+			steps: []step{
+				{0, Decision{Allowed: true, Limit: 2, Remaining: 1, Reset: 5 * time.Second}},
+				{0, Decision{Allowed: true, Limit: 2, Remaining: 0, Reset: 10 * time.Second}},
+				{0, Decision{Limit: 2, RetryAfter: 5 * time.Second, Reset: 10 * time.Second}},
+			},
+		},
+		{
+			name: "refills at the average rate",
+			steps: []step{
+				{0, Decision{Allowed: true, Limit: 2, Remaining: 1, Reset: 5 * time.Second}},
+				{0, Decision{Allowed: true, Limit: 2, Remaining: 0, Reset: 10 * time.Second}},
+				{2 * time.Second, Decision{Limit: 2, RetryAfter: 3 * time.Second, Reset: 8 * time.Second}},
+				{5 * time.Second, Decision{Allowed: true, Limit: 2, Remaining: 0, Reset: 10 * time.Second}},
+			},
+		},
+		{
+			name: "refill is capped at the burst",
+			steps: []step{
+				{0, Decision{Allowed: true, Limit: 2, Remaining: 1, Reset: 5 * time.Second}},
+				{time.Hour, Decision{Allowed: true, Limit: 2, Remaining: 1, Reset: 5 * time.Second}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := New(limit, 10)
+			for i, s := range tt.steps {
+				if got := l.Allow("k", epoch.Add(s.at)); !sameDecision(got, s.want) {
+					t.Errorf("step %d: Allow = %+v, want %+v", i, got, s.want)
+				}
+			}
+		})
+	}
+}
+
+func TestPeekTakesNoToken(t *testing.T) {
+	l := New(Limit{Requests: 1, Period: time.Second}, 10)
+
+	if d := l.Peek("k", epoch); !d.Allowed || d.Remaining != 1 {
+		t.Fatalf("Peek on a new key = %+v, want allowed with 1 remaining", d)
+	}
+	if d := l.Allow("k", epoch); !d.Allowed {
+		t.Fatalf("Allow after Peek = %+v, want allowed", d)
+	}
+	if d := l.Peek("k", epoch); d.Allowed || d.RetryAfter != time.Second {
+		t.Errorf("Peek on an empty bucket = %+v, want rejected with a 1s retry", d)
+	}
+	if d := l.Peek("k", epoch.Add(time.Second)); !d.Allowed {
+		t.Errorf("Peek after a refill = %+v, want allowed", d)
+	}
+}
+
+func TestKeysAreIndependent(t *testing.T) {
+	l := New(Limit{Requests: 1, Period: time.Minute}, 10)
+	l.Allow("a", epoch)
+	if d := l.Allow("a", epoch); d.Allowed {
+		t.Errorf("second request of a = %+v, want rejected", d)
+	}
+	if d := l.Allow("b", epoch); !d.Allowed {
+		t.Errorf("first request of b = %+v, want allowed", d)
+	}
+}
+
+func TestEvictsLeastRecentlyUsed(t *testing.T) {
+	l := New(Limit{Requests: 1, Period: time.Minute}, 2)
+	l.Allow("a", epoch)
+	l.Allow("b", epoch)
+	// Using a again makes b the least recently used
+	l.Allow("a", epoch)
+	l.Allow("c", epoch)
+
+	if len(l.buckets) != 2 || l.recent.Len() != 2 {
+		t.Fatalf("kept %d buckets in a list of %d, want 2", len(l.buckets), l.recent.Len())
+	}
+	if _, ok := l.buckets["b"]; ok {
+		t.Error("b was kept, want it evicted")
+	}
+	// a and c are still empty, while b starts over with a full bucket
+	for key, allowed := range map[string]bool{"a": false, "c": false, "b": true} {
+		if d := l.Peek(key, epoch); d.Allowed != allowed {
+			t.Errorf("Peek(%s).Allowed = %v, want %v", key, d.Allowed, allowed)
+		}
+	}
+}
+
+func TestSweep(t *testing.T) {
+	period := time.Minute
+	tests := []struct {
+		name     string
+		idle     []time.Duration // how long before the sweep each key was last used
+		wantKept int
+	}{
+		{"nothing idle", []time.Duration{30 * time.Second, 10 * time.Second}, 2},
+		{"idle exactly a period", []time.Duration{period, 10 * time.Second}, 1},
+		{"all idle", []time.Duration{2 * period, period}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := New(Limit{Requests: 5, Period: period}, 100)
+			now := epoch.Add(10 * period)
+			// Keys are used oldest first so the list order matches their age
+			for i, idle := range tt.idle {
+				l.Allow(fmt.Sprint(i), now.Add(-idle))
+			}
+			l.sweep(now)
+
+			if len(l.buckets) != tt.wantKept || l.recent.Len() != tt.wantKept {
+				t.Errorf("kept %d buckets in a list of %d, want %d", len(l.buckets), l.recent.Len(), tt.wantKept)
+			}
+			if !l.lastSweep.Equal(now) {
+				t.Errorf("lastSweep = %v, want %v", l.lastSweep, now)
+			}
+		})
+	}
+}
+
+func TestAllowSweepsOncePerPeriod(t *testing.T) {
+	period := time.Minute
+	l := New(Limit{Requests: 5, Period: period}, 100)
+	// The first call sweeps, and so does the first one a period later
+	l.Allow("new", epoch)
+	l.Allow("old", epoch.Add(period/2))
+	l.Allow("new", epoch.Add(period))
+
+	// old has now been idle for more than a period, but the next sweep is
+	// not due yet
+	l.Allow("new", epoch.Add(period+3*period/4))
+	if _, ok := l.buckets["old"]; !ok {
+		t.Fatal("old was swept before a period had passed since the last sweep")
+	}
+
+	l.Allow("new", epoch.Add(2*period))
+	if _, ok := l.buckets["old"]; ok {
+		t.Error("old survived a sweep after being idle for more than a period")
+	}
+}
+
+func BenchmarkAllowDistinctKeys(b *testing.B) {
+	l := New(Limit{Requests: 10, Period: time.Minute}, 1000)
+	now := epoch
+	for i := 0; i < b.N; i++ {
+		l.Allow(fmt.Sprint(i), now)
+	}
+}
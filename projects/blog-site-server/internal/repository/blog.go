@@ -2,25 +2,64 @@ package repository
 
 import (
 	"blog-app/internal/models"
+	"context"
 	"database/sql"
 	"time"
+
+	"github.com/lib/pq"
 )
 
 type BlogRepository struct {
-	db *sql.DB
+	db      *sql.DB
+	timeout queryTimeout
+}
+
+// NewBlogRepository creates a repository whose calls are each cancelled
+// after timeout; zero disables the deadline
+func NewBlogRepository(db *sql.DB, timeout time.Duration) *BlogRepository {
+	return &BlogRepository{db: db, timeout: queryTimeout(timeout)}
 }
 
-func NewBlogRepository(db *sql.DB) *BlogRepository {
-	return &BlogRepository{db: db}
+// blogColumns is the select list matching scanBlog
+const blogColumns = `id, title, content, cover_image, author_id, created_at, updated_at, version, ` + blogTagsColumn
+
+// scanBlog reads a row selected with blogColumns
+func scanBlog(row rowScanner) (*models.Blog, error) {
+	blog := &models.Blog{}
+	err := row.Scan(
+		&blog.ID,
+		&blog.Title,
+		&blog.Content,
+		&blog.CoverImage,
+		&blog.AuthorID,
+		&blog.CreatedAt,
+		&blog.UpdatedAt,
+		&blog.Version,
+		pq.Array(&blog.Tags),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return blog, nil
 }
 
-// Create inserts a new blog post into the database
-func (r *BlogRepository) Create(blog *models.Blog) error {
+// Create inserts a new blog post with its tags into the database and fills
+// in blog with the stored row
+func (r *BlogRepository) Create(ctx context.Context, blog *models.Blog) error {
+	ctx, cancel := r.timeout.start(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return classify(ctx, err)
+	}
+	defer tx.Rollback()
+
 	query := `INSERT INTO blogs (title, content, cover_image, author_id, created_at, updated_at)
-			  VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+			  VALUES ($1, $2, $3, $4, $5, $6) RETURNING ` + blogColumns
 
 	now := time.Now()
-	return r.db.QueryRow(
+	stored, err := scanBlog(tx.QueryRowContext(ctx,
 		query,
 		blog.Title,
 		blog.Content,
@@ -28,81 +67,246 @@ func (r *BlogRepository) Create(blog *models.Blog) error {
 		blog.AuthorID,
 		now,
 		now,
-	).Scan(&blog.ID)
+	))
+	if err != nil {
+		return classify(ctx, err)
+	}
+	if err := setBlogTags(ctx, tx, stored.ID, blog.Tags); err != nil {
+		return classify(ctx, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return classify(ctx, err)
+	}
+	stored.Tags = append([]string{}, blog.Tags...)
+	*blog = *stored
+	return nil
 }
 
 // GetByID retrieves a blog post by its ID
-func (r *BlogRepository) GetByID(id int64) (*models.Blog, error) {
-	query := `SELECT id, title, content, cover_image, author_id, created_at, updated_at
-			  FROM blogs WHERE id = $1`
+func (r *BlogRepository) GetByID(ctx context.Context, id int64) (*models.Blog, error) {
+	ctx, cancel := r.timeout.start(ctx)
+	defer cancel()
 
-	blog := &models.Blog{}
-	err := r.db.QueryRow(query, id).Scan(
-		&blog.ID,
-		&blog.Title,
-		&blog.Content,
-		&blog.CoverImage,
-		&blog.AuthorID,
-		&blog.CreatedAt,
-		&blog.UpdatedAt,
-	)
+	query := `SELECT ` + blogColumns + ` FROM blogs WHERE id = $1`
+	blog, err := scanBlog(r.db.QueryRowContext(ctx, query, id))
+	return blog, classify(ctx, err)
+}
+
+// GetAll retrieves one page of blog posts matching the filter, in the
+// filter's order, along with the cursor for the next page
+func (r *BlogRepository) GetAll(ctx context.Context, filter BlogFilter, page Page) ([]*models.Blog, *Cursor, error) {
+	ctx, cancel := r.timeout.start(ctx)
+	defer cancel()
+
+	limit := page.limit()
+
+	q := &queryBuilder{}
+	if err := filter.apply(q, page); err != nil {
+		return nil, nil, classify(ctx, err)
+	}
+	query := `SELECT ` + blogColumns + ` FROM blogs` +
+		q.whereClause() + filter.orderBy() + ` LIMIT ` + q.arg(limit+1)
+
+	rows, err := r.db.QueryContext(ctx, query, q.args...)
 	if err != nil {
-		return nil, err
+		return nil, nil, classify(ctx, err)
 	}
-	return blog, nil
+	defer rows.Close()
+
+	var blogs []*models.Blog
+	for rows.Next() {
+		blog, err := scanBlog(rows)
+		if err != nil {
+			return nil, nil, classify(ctx, err)
+		}
+		blogs = append(blogs, blog)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, classify(ctx, err)
+	}
+
+	name, spec := filter.sortSpec()
+	blogs, next := nextPage(blogs, limit, func(blog *models.Blog) Cursor {
+		return spec.cursor(name, blog)
+	})
+	return blogs, next, nil
 }
 
-// GetAll retrieves all blog posts
-func (r *BlogRepository) GetAll() ([]*models.Blog, error) {
-	query := `SELECT id, title, content, cover_image, author_id, created_at, updated_at
-			  FROM blogs ORDER BY created_at DESC`
+// Search retrieves one page of blog posts matching the full-text query,
+// best match first, with highlighted titles and content snippets
+func (r *BlogRepository) Search(ctx context.Context, search SearchQuery, page Page) ([]*models.BlogSearchResult, *Cursor, error) {
+	ctx, cancel := r.timeout.start(ctx)
+	defer cancel()
+
+	limit := page.limit()
+
+	q := &queryBuilder{}
+	tsquery := q.arg(search.tsquery())
+	rank := `ts_rank_cd(search, query)::float8`
+	q.where(`search @@ query`)
+	if page.After != nil {
+		if !page.After.resumes(searchKind, searchSort, 0) {
+			return nil, nil, ErrInvalidCursor
+		}
+		q.where(`(` + rank + `, id) < (` + q.arg(page.After.Rank) + `, ` + q.arg(page.After.ID) + `)`)
+	}
+
+	query := `SELECT id, title, content, cover_image, author_id, created_at, updated_at, ` + blogTagsColumn + `, ` + rank + `,
+			  ts_headline('english', title, query, ` + q.arg(titleHeadlineOptions) + `),
+			  ts_headline('english', content, query, ` + q.arg(headlineOptions) + `)
+			  FROM blogs, to_tsquery('english', ` + tsquery + `) AS query` +
+		q.whereClause() + `
+			  ORDER BY ` + rank + ` DESC, id DESC LIMIT ` + q.arg(limit+1)
 
-	rows, err := r.db.Query(query)
+	rows, err := r.db.QueryContext(ctx, query, q.args...)
 	if err != nil {
-		return nil, err
+		return nil, nil, classify(ctx, err)
 	}
 	defer rows.Close()
 
-	var blogs []*models.Blog
+	var results []*models.BlogSearchResult
 	for rows.Next() {
-		blog := &models.Blog{}
+		result := &models.BlogSearchResult{}
 		err := rows.Scan(
-			&blog.ID,
-			&blog.Title,
-			&blog.Content,
-			&blog.CoverImage,
-			&blog.AuthorID,
-			&blog.CreatedAt,
-			&blog.UpdatedAt,
+			&result.ID,
+			&result.Title,
+			&result.Content,
+			&result.CoverImage,
+			&result.AuthorID,
+			&result.CreatedAt,
+			&result.UpdatedAt,
+			pq.Array(&result.Tags),
+			&result.Rank,
+			&result.TitleHighlight,
+			&result.Snippet,
 		)
 		if err != nil {
-			return nil, err
+			return nil, nil, classify(ctx, err)
 		}
-		blogs = append(blogs, blog)
+		result.TitleHighlight = renderHighlight(result.TitleHighlight)
+		result.Snippet = renderHighlight(result.Snippet)
+		results = append(results, result)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, classify(ctx, err)
+	}
+
+	results, next := nextPage(results, limit, searchCursor)
+	return results, next, nil
+}
 
-	return blogs, rows.Err()
+// searchCursor returns the keyset position of a search result
+func searchCursor(result *models.BlogSearchResult) Cursor {
+	return Cursor{Kind: searchKind, Sort: searchSort, Rank: result.Rank, ID: result.ID}
 }
 
-// Update updates an existing blog post
-func (r *BlogRepository) Update(blog *models.Blog) error {
-	query := `UPDATE blogs SET title = $1, content = $2, cover_image = $3, updated_at = $4
-			  WHERE id = $5`
+// Update updates an existing blog post and replaces its tags if its stored
+// version is ifVersion, and fills in blog with the stored row
+func (r *BlogRepository) Update(ctx context.Context, blog *models.Blog, ifVersion int64) error {
+	ctx, cancel := r.timeout.start(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return classify(ctx, err)
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE blogs SET title = $1, content = $2, cover_image = $3, updated_at = $4, version = version + 1
+			  WHERE id = $5 AND ` + versionCond("$6") + ` RETURNING ` + blogColumns
 
-	_, err := r.db.Exec(
+	stored, err := scanBlog(tx.QueryRowContext(ctx,
 		query,
 		blog.Title,
 		blog.Content,
 		blog.CoverImage,
 		time.Now(),
 		blog.ID,
-	)
-	return err
+		ifVersion,
+	))
+	if err == sql.ErrNoRows {
+		return missOrConflict(ctx, tx, "blogs", blog.ID)
+	}
+	if err != nil {
+		return classify(ctx, err)
+	}
+	if err := setBlogTags(ctx, tx, stored.ID, blog.Tags); err != nil {
+		return classify(ctx, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return classify(ctx, err)
+	}
+	stored.Tags = append([]string{}, blog.Tags...)
+	*blog = *stored
+	return nil
 }
 
-// Delete deletes a blog post by its ID
-func (r *BlogRepository) Delete(id int64) error {
-	query := `DELETE FROM blogs WHERE id = $1`
-	_, err := r.db.Exec(query, id)
-	return err
+// Patch applies a merge patch if the stored version is ifVersion, writing
+// only the touched columns, and returns the stored row. Tags in the patch
+// must be normalized.
+func (r *BlogRepository) Patch(ctx context.Context, id int64, patch *models.BlogPatch, ifVersion int64) (*models.Blog, error) {
+	ctx, cancel := r.timeout.start(ctx)
+	defer cancel()
+
+	if patch.Empty() {
+		blog, err := r.GetByID(ctx, id)
+		if err == nil && !versionMatches(blog.Version, ifVersion) {
+			return nil, ErrVersionConflict
+		}
+		return blog, err
+	}
+
+	q := &queryBuilder{}
+	if patch.Title.Set {
+		q.set("title", patch.Title.Value)
+	}
+	if patch.Content.Set {
+		q.set("content", patch.Content.Value)
+	}
+	if patch.CoverImage.Set {
+		q.set("cover_image", patch.CoverImage.Value)
+	}
+	q.set("updated_at", time.Now())
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, classify(ctx, err)
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE blogs SET ` + q.setClause() + `, version = version + 1
+			  WHERE id = ` + q.arg(id) + ` AND ` + versionCond(q.arg(ifVersion)) + `
+			  RETURNING ` + blogColumns
+	blog, err := scanBlog(tx.QueryRowContext(ctx, query, q.args...))
+	if err == sql.ErrNoRows {
+		return nil, missOrConflict(ctx, tx, "blogs", id)
+	}
+	if err != nil {
+		return nil, classify(ctx, err)
+	}
+	if patch.Tags.Set {
+		if err := setBlogTags(ctx, tx, id, patch.Tags.Value); err != nil {
+			return nil, classify(ctx, err)
+		}
+		blog.Tags = append([]string{}, patch.Tags.Value...)
+	}
+	return blog, classify(ctx, tx.Commit())
+}
+
+// Delete deletes a blog post by its ID if its stored version is ifVersion
+func (r *BlogRepository) Delete(ctx context.Context, id, ifVersion int64) error {
+	ctx, cancel := r.timeout.start(ctx)
+	defer cancel()
+
+	query := `DELETE FROM blogs WHERE id = $1 AND ` + versionCond("$2")
+	result, err := r.db.ExecContext(ctx, query, id, ifVersion)
+	if err != nil {
+		return classify(ctx, err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return classify(ctx, err)
+	} else if n == 0 {
+		return missOrConflict(ctx, r.db, "blogs", id)
+	}
+	return nil
 }
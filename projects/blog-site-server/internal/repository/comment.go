@@ -2,47 +2,40 @@ package repository
 
 import (
 	"blog-app/internal/models"
+	"context"
 	"database/sql"
 	"time"
+
+	"github.com/lib/pq"
 )
 
 type CommentRepository struct {
-	db *sql.DB
-}
-
-func NewCommentRepository(db *sql.DB) *CommentRepository {
-	return &CommentRepository{db: db}
+	db      *sql.DB
+	timeout queryTimeout
 }
 
-// Create inserts a new comment into the database
-func (r *CommentRepository) Create(comment *models.Comment) error {
-	query := `INSERT INTO comments (post_id, user_id, content, created_at, updated_at)
-			  VALUES ($1, $2, $3, $4, $5) RETURNING id`
-
-	now := time.Now()
-	return r.db.QueryRow(
-		query,
-		comment.PostID,
-		comment.UserID,
-		comment.Content,
-		now,
-		now,
-	).Scan(&comment.ID)
+// NewCommentRepository creates a repository whose calls are each cancelled
+// after timeout; zero disables the deadline
+func NewCommentRepository(db *sql.DB, timeout time.Duration) *CommentRepository {
+	return &CommentRepository{db: db, timeout: queryTimeout(timeout)}
 }
 
-// GetByID retrieves a comment by its ID
-func (r *CommentRepository) GetByID(id int64) (*models.Comment, error) {
-	query := `SELECT id, post_id, user_id, content, created_at, updated_at
-			  FROM comments WHERE id = $1`
+// commentColumns is the select list matching scanComment
+const commentColumns = `id, post_id, user_id, parent_id, content, is_deleted, created_at, updated_at, version`
 
+// scanComment reads a row selected with commentColumns
+func scanComment(row rowScanner) (*models.Comment, error) {
 	comment := &models.Comment{}
-	err := r.db.QueryRow(query, id).Scan(
+	err := row.Scan(
 		&comment.ID,
 		&comment.PostID,
 		&comment.UserID,
+		&comment.ParentID,
 		&comment.Content,
+		&comment.Deleted,
 		&comment.CreatedAt,
 		&comment.UpdatedAt,
+		&comment.Version,
 	)
 	if err != nil {
 		return nil, err
@@ -50,48 +43,261 @@ func (r *CommentRepository) GetByID(id int64) (*models.Comment, error) {
 	return comment, nil
 }
 
-// GetByBlogID retrieves all comments for a specific blog post
-func (r *CommentRepository) GetByBlogID(blogID int64) ([]*models.Comment, error) {
-	query := `SELECT id, post_id, user_id, content, created_at, updated_at
-			  FROM comments WHERE post_id = $1 ORDER BY created_at DESC`
-
-	rows, err := r.db.Query(query, blogID)
-	if err != nil {
-		return nil, err
-	}
+// scanComments drains rows selected with commentColumns
+func scanComments(rows *sql.Rows) ([]*models.Comment, error) {
 	defer rows.Close()
 
 	var comments []*models.Comment
 	for rows.Next() {
-		comment := &models.Comment{}
-		err := rows.Scan(
-			&comment.ID,
-			&comment.PostID,
-			&comment.UserID,
-			&comment.Content,
-			&comment.CreatedAt,
-			&comment.UpdatedAt,
-		)
+		comment, err := scanComment(rows)
 		if err != nil {
 			return nil, err
 		}
 		comments = append(comments, comment)
 	}
-
 	return comments, rows.Err()
 }
 
-// Update updates an existing comment
-func (r *CommentRepository) Update(comment *models.Comment) error {
-	query := `UPDATE comments SET content = $1, updated_at = $2 WHERE id = $3`
+// Create inserts a new comment into the database and fills in comment with
+// the stored row
+func (r *CommentRepository) Create(ctx context.Context, comment *models.Comment) error {
+	ctx, cancel := r.timeout.start(ctx)
+	defer cancel()
+
+	query := `INSERT INTO comments (post_id, user_id, parent_id, content, created_at, updated_at)
+			  VALUES ($1, $2, $3, $4, $5, $6) RETURNING ` + commentColumns
+
+	now := time.Now()
+	stored, err := scanComment(r.db.QueryRowContext(ctx,
+		query,
+		comment.PostID,
+		comment.UserID,
+		comment.ParentID,
+		comment.Content,
+		now,
+		now,
+	))
+	if err != nil {
+		return classify(ctx, err)
+	}
+	*comment = *stored
+	return nil
+}
+
+// GetByID retrieves a comment by its ID
+func (r *CommentRepository) GetByID(ctx context.Context, id int64) (*models.Comment, error) {
+	ctx, cancel := r.timeout.start(ctx)
+	defer cancel()
+
+	query := `SELECT ` + commentColumns + ` FROM comments WHERE id = $1`
+	comment, err := scanComment(r.db.QueryRowContext(ctx, query, id))
+	return comment, classify(ctx, err)
+}
+
+// GetByBlogID retrieves one page of comments for a specific blog post,
+// newest first, along with the cursor for the next page
+func (r *CommentRepository) GetByBlogID(ctx context.Context, blogID int64, page Page) ([]*models.Comment, *Cursor, error) {
+	return r.list(ctx, commentsKind, `post_id = $1`, blogID, page)
+}
+
+// GetRootsByBlogID retrieves one page of top-level comments for a specific
+// blog post, newest first, along with the cursor for the next page
+func (r *CommentRepository) GetRootsByBlogID(ctx context.Context, blogID int64, page Page) ([]*models.Comment, *Cursor, error) {
+	return r.list(ctx, commentRootsKind, `post_id = $1 AND parent_id IS NULL`, blogID, page)
+}
+
+// list pages through the comments of a blog post matching cond, recording
+// kind in the cursors it issues
+func (r *CommentRepository) list(ctx context.Context, kind, cond string, blogID int64, page Page) ([]*models.Comment, *Cursor, error) {
+	ctx, cancel := r.timeout.start(ctx)
+	defer cancel()
+
+	limit := page.limit()
+	if !page.After.resumes(kind, newestFirstSort, blogID) {
+		return nil, nil, ErrInvalidCursor
+	}
+
+	query := `SELECT ` + commentColumns + ` FROM comments WHERE ` + cond + `
+			  ORDER BY created_at DESC, id DESC LIMIT $2`
+	args := []any{blogID, limit + 1}
+	if page.After != nil {
+		query = `SELECT ` + commentColumns + ` FROM comments
+				 WHERE ` + cond + ` AND (created_at, id) < ($3, $4)
+				 ORDER BY created_at DESC, id DESC LIMIT $2`
+		args = append(args, page.After.Time, page.After.ID)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, classify(ctx, err)
+	}
+	comments, err := scanComments(rows)
+	if err != nil {
+		return nil, nil, classify(ctx, err)
+	}
+
+	comments, next := nextPage(comments, limit, func(comment *models.Comment) Cursor {
+		return commentCursor(kind, comment)
+	})
+	return comments, next, nil
+}
+
+// GetReplies retrieves the replies below the given comments, oldest first,
+// down to maxDepth levels. Replies one level deeper are included as well so
+// that callers can tell which comments have been cut off.
+func (r *CommentRepository) GetReplies(ctx context.Context, parentIDs []int64, maxDepth int) ([]*models.Comment, error) {
+	ctx, cancel := r.timeout.start(ctx)
+	defer cancel()
+
+	if len(parentIDs) == 0 {
+		return nil, nil
+	}
+
+	query := `WITH RECURSIVE thread AS (
+				SELECT ` + commentColumns + `, 1 AS depth
+				FROM comments WHERE parent_id = ANY($1)
+				UNION ALL
+				SELECT c.id, c.post_id, c.user_id, c.parent_id, c.content, c.is_deleted, c.created_at, c.updated_at, c.version, t.depth + 1
+				FROM comments c JOIN thread t ON c.parent_id = t.id
+				WHERE t.depth <= $2
+			  )
+			  SELECT ` + commentColumns + ` FROM thread ORDER BY created_at, id`
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(parentIDs), maxDepth)
+	if err != nil {
+		return nil, classify(ctx, err)
+	}
+	comments, err := scanComments(rows)
+	return comments, classify(ctx, err)
+}
+
+// commentCursor returns the keyset position of a comment in a listing of
+// the given kind, scoped to its blog post
+func commentCursor(kind string, comment *models.Comment) Cursor {
+	return Cursor{Kind: kind, Sort: newestFirstSort, Scope: comment.PostID, Time: comment.CreatedAt, ID: comment.ID}
+}
+
+// Update updates an existing comment if its stored version is ifVersion
+// and fills in comment with the stored row
+func (r *CommentRepository) Update(ctx context.Context, comment *models.Comment, ifVersion int64) error {
+	ctx, cancel := r.timeout.start(ctx)
+	defer cancel()
+
+	query := `UPDATE comments SET content = $1, updated_at = $2, version = version + 1
+			  WHERE id = $3 AND ` + versionCond("$4") + ` RETURNING ` + commentColumns
 
-	_, err := r.db.Exec(query, comment.Content, time.Now(), comment.ID)
-	return err
+	stored, err := scanComment(r.db.QueryRowContext(ctx, query, comment.Content, time.Now(), comment.ID, ifVersion))
+	if err == sql.ErrNoRows {
+		return missOrConflict(ctx, r.db, "comments", comment.ID)
+	}
+	if err != nil {
+		return classify(ctx, err)
+	}
+	*comment = *stored
+	return nil
 }
 
-// Delete deletes a comment by its ID
-func (r *CommentRepository) Delete(id int64) error {
-	query := `DELETE FROM comments WHERE id = $1`
-	_, err := r.db.Exec(query, id)
-	return err
+// Patch applies a merge patch if the stored version is ifVersion, writing
+// only the touched columns, and returns the stored row
+func (r *CommentRepository) Patch(ctx context.Context, id int64, patch *models.CommentPatch, ifVersion int64) (*models.Comment, error) {
+	ctx, cancel := r.timeout.start(ctx)
+	defer cancel()
+
+	if patch.Empty() {
+		comment, err := r.GetByID(ctx, id)
+		if err == nil && !versionMatches(comment.Version, ifVersion) {
+			return nil, ErrVersionConflict
+		}
+		return comment, err
+	}
+
+	q := &queryBuilder{}
+	if patch.Content.Set {
+		q.set("content", patch.Content.Value)
+	}
+	q.set("updated_at", time.Now())
+
+	query := `UPDATE comments SET ` + q.setClause() + `, version = version + 1
+			  WHERE id = ` + q.arg(id) + ` AND ` + versionCond(q.arg(ifVersion)) + `
+			  RETURNING ` + commentColumns
+	comment, err := scanComment(r.db.QueryRowContext(ctx, query, q.args...))
+	if err == sql.ErrNoRows {
+		return nil, missOrConflict(ctx, r.db, "comments", id)
+	}
+	return comment, classify(ctx, err)
+}
+
+// Delete deletes a comment by its ID. A comment that still has replies is
+// kept as a "[deleted]" placeholder so the thread stays intact; removing
+// the last reply of a placeholder removes the placeholder too, while
+// deleting a placeholder that still has replies changes nothing. Only the
+// comment itself is checked against ifVersion.
+func (r *CommentRepository) Delete(ctx context.Context, id, ifVersion int64) error {
+	ctx, cancel := r.timeout.start(ctx)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return classify(ctx, err)
+	}
+	defer tx.Rollback()
+
+	for first := true; ; first = false {
+		// Locking the row blocks concurrent replies, which take a key
+		// share lock on their parent, and concurrent writes
+		var parentID sql.NullInt64
+		var version int64
+		var deleted bool
+		err := tx.QueryRowContext(ctx, `SELECT parent_id, version, is_deleted FROM comments WHERE id = $1 FOR UPDATE`, id).Scan(&parentID, &version, &deleted)
+		if err == sql.ErrNoRows {
+			if first {
+				return classify(ctx, err)
+			}
+			break
+		}
+		if err != nil {
+			return classify(ctx, err)
+		}
+		if first && !versionMatches(version, ifVersion) {
+			return ErrVersionConflict
+		}
+
+		var hasReplies bool
+		err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM comments WHERE parent_id = $1)`, id).Scan(&hasReplies)
+		if err != nil {
+			return classify(ctx, err)
+		}
+
+		if hasReplies {
+			// A placeholder stays as it is, so its ETag remains valid
+			if deleted {
+				break
+			}
+			query := `UPDATE comments SET content = $1, is_deleted = TRUE, updated_at = $2, version = version + 1 WHERE id = $3`
+			if _, err := tx.ExecContext(ctx, query, models.DeletedCommentContent, time.Now(), id); err != nil {
+				return classify(ctx, err)
+			}
+			break
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM comments WHERE id = $1`, id); err != nil {
+			return classify(ctx, err)
+		}
+		if !parentID.Valid {
+			break
+		}
+
+		// Prune the parent if it is a placeholder left without replies
+		var parentDeleted bool
+		err = tx.QueryRowContext(ctx, `SELECT is_deleted FROM comments WHERE id = $1`, parentID.Int64).Scan(&parentDeleted)
+		if err != nil && err != sql.ErrNoRows {
+			return classify(ctx, err)
+		}
+		if !parentDeleted {
+			break
+		}
+		id = parentID.Int64
+	}
+
+	return classify(ctx, tx.Commit())
 }
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+// Kinds of constraint violation. Match them with errors.Is; errors.As with
+// *ConstraintError gives the constraint and field.
+var (
+	ErrDuplicate        = errors.New("duplicate value")
+	ErrMissingReference = errors.New("referenced row does not exist")
+	ErrCheckViolation   = errors.New("value violates a check constraint")
+	ErrValueTooLong     = errors.New("value too long")
+)
+
+// ConstraintError is a write rejected by a database constraint
+type ConstraintError struct {
+	// Kind is one of the Err* sentinels above
+	Kind       error
+	Table      string
+	Constraint string
+	// Field is the JSON name of the offending input, if known
+	Field string
+	// Cause is the driver error, nil for the in-memory store
+	Cause error
+}
+
+func (e *ConstraintError) Error() string {
+	if e.Constraint == "" {
+		return fmt.Sprintf("%v on table %q", e.Kind, e.Table)
+	}
+	return fmt.Sprintf("%v: constraint %q on table %q", e.Kind, e.Constraint, e.Table)
+}
+
+func (e *ConstraintError) Unwrap() []error {
+	if e.Cause == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Cause}
+}
+
+// constraintFields names the input field behind each constraint
+var constraintFields = map[string]string{
+	"users_username_key":    "username",
+	"users_email_key":       "email",
+	"blogs_author_id_fkey":  "author_id",
+	"comments_post_id_fkey": "post_id",
+	"comments_user_id_fkey": "user_id",
+	"comments_parent_fkey":  "parent_id",
+	"sessions_user_id_fkey": "user_id",
+	"tags_slug_check":       "tags",
+}
+
+// newConstraintError builds the error for a violated constraint
+func newConstraintError(kind error, table, constraint string) *ConstraintError {
+	return &ConstraintError{
+		Kind:       kind,
+		Table:      table,
+		Constraint: constraint,
+		Field:      constraintFields[constraint],
+	}
+}
+
+// pqErrorKinds maps Postgres error codes onto constraint kinds
+var pqErrorKinds = map[pq.ErrorCode]error{
+	"23505": ErrDuplicate,        // unique_violation
+	"23503": ErrMissingReference, // foreign_key_violation
+	"23514": ErrCheckViolation,   // check_violation
+	"22001": ErrValueTooLong,     // string_data_right_truncation
+}
+
+// classify prepares an error from a Postgres call for the caller. Once ctx
+// is done the error wraps ctx.Err(), since the driver reports a cancelled
+// statement as an ordinary server error. Constraint violations become
+// *ConstraintError; every other error passes through unchanged.
+func classify(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil && !errors.Is(err, ctxErr) {
+		return fmt.Errorf("%w: %w", ctxErr, err)
+	}
+
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return err
+	}
+	kind, ok := pqErrorKinds[pqErr.Code]
+	if !ok {
+		return err
+	}
+
+	constraintErr := newConstraintError(kind, pqErr.Table, pqErr.Constraint)
+	if constraintErr.Field == "" {
+		constraintErr.Field = pqErr.Column
+	}
+	constraintErr.Cause = err
+	return constraintErr
+}
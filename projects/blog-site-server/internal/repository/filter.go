@@ -0,0 +1,210 @@
+package repository
+
+import (
+	"blog-app/internal/models"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// DefaultBlogSort is the ordering used when the client does not pick one
+const DefaultBlogSort = "-created_at"
+
+// BlogFilter narrows and orders a blog listing. Nil fields are not applied.
+type BlogFilter struct {
+	AuthorID      *int64
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	UpdatedSince  *time.Time
+	// Tags holds normalized, distinct slugs. A post matches when it carries
+	// any of them, or all of them with MatchAllTags.
+	Tags         []string
+	MatchAllTags bool
+	Sort         string
+}
+
+// blogSort describes one whitelisted ordering. Only these expressions are
+// ever interpolated into SQL; client input merely selects one of them.
+type blogSort struct {
+	expr string
+	desc bool
+	// text marks string-valued keys; the others are timestamps
+	text bool
+	// key extracts the sort value for the in-memory store and cursors
+	key func(blog *models.Blog) (time.Time, string)
+}
+
+var blogSorts = map[string]blogSort{
+	"created_at":  {expr: "created_at", key: blogCreatedAt},
+	"-created_at": {expr: "created_at", desc: true, key: blogCreatedAt},
+	"updated_at":  {expr: "COALESCE(updated_at, created_at)", key: blogUpdatedAt},
+	"-updated_at": {expr: "COALESCE(updated_at, created_at)", desc: true, key: blogUpdatedAt},
+	"title":       {expr: "title", text: true, key: blogTitle},
+	"-title":      {expr: "title", desc: true, text: true, key: blogTitle},
+}
+
+func blogCreatedAt(blog *models.Blog) (time.Time, string) {
+	return blog.CreatedAt, ""
+}
+
+func blogUpdatedAt(blog *models.Blog) (time.Time, string) {
+	if blog.UpdatedAt != nil {
+		return *blog.UpdatedAt, ""
+	}
+	return blog.CreatedAt, ""
+}
+
+func blogTitle(blog *models.Blog) (time.Time, string) {
+	return time.Time{}, blog.Title
+}
+
+// BlogSorts lists the accepted values of the sort parameter
+func BlogSorts() []string {
+	names := make([]string, 0, len(blogSorts))
+	for name := range blogSorts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ValidBlogSort reports whether name is a whitelisted ordering
+func ValidBlogSort(name string) bool {
+	_, ok := blogSorts[name]
+	return ok
+}
+
+// sortSpec returns the ordering for the filter, falling back to the default
+func (f BlogFilter) sortSpec() (string, blogSort) {
+	name := f.Sort
+	if name == "" {
+		name = DefaultBlogSort
+	}
+	spec, ok := blogSorts[name]
+	if !ok {
+		return DefaultBlogSort, blogSorts[DefaultBlogSort]
+	}
+	return name, spec
+}
+
+// cursor returns the keyset position of a blog under this ordering
+func (s blogSort) cursor(name string, blog *models.Blog) Cursor {
+	t, text := s.key(blog)
+	return Cursor{Kind: blogsKind, Sort: name, Time: t, Text: text, ID: blog.ID}
+}
+
+// compare orders two blogs under this ordering, returning a negative value
+// when a comes first
+func (s blogSort) compare(a, b *models.Blog) int {
+	aTime, aText := s.key(a)
+	bTime, bText := s.key(b)
+	return s.compareKeys(aTime, aText, a.ID, bTime, bText, b.ID)
+}
+
+// compareKeys orders two (key, id) pairs under this ordering
+func (s blogSort) compareKeys(aTime time.Time, aText string, aID int64, bTime time.Time, bText string, bID int64) int {
+	var c int
+	if s.text {
+		c = strings.Compare(aText, bText)
+	} else {
+		c = aTime.Compare(bTime)
+	}
+	if c == 0 {
+		switch {
+		case aID < bID:
+			c = -1
+		case aID > bID:
+			c = 1
+		}
+	}
+	if s.desc {
+		return -c
+	}
+	return c
+}
+
+// matches reports whether a blog passes the filter's predicates
+func (f BlogFilter) matches(blog *models.Blog) bool {
+	if f.AuthorID != nil && blog.AuthorID != *f.AuthorID {
+		return false
+	}
+	if f.CreatedAfter != nil && !blog.CreatedAt.After(*f.CreatedAfter) {
+		return false
+	}
+	if f.CreatedBefore != nil && !blog.CreatedAt.Before(*f.CreatedBefore) {
+		return false
+	}
+	if f.UpdatedSince != nil {
+		updated, _ := blogUpdatedAt(blog)
+		if updated.Before(*f.UpdatedSince) {
+			return false
+		}
+	}
+	if len(f.Tags) > 0 {
+		matched := 0
+		for _, tag := range f.Tags {
+			if slices.Contains(blog.Tags, tag) {
+				matched++
+			}
+		}
+		if matched == 0 || (f.MatchAllTags && matched < len(f.Tags)) {
+			return false
+		}
+	}
+	return true
+}
+
+// apply adds the filter predicates and keyset condition for the page
+func (f BlogFilter) apply(q *queryBuilder, page Page) error {
+	name, spec := f.sortSpec()
+
+	if f.AuthorID != nil {
+		q.where("author_id = " + q.arg(*f.AuthorID))
+	}
+	if f.CreatedAfter != nil {
+		q.where("created_at > " + q.arg(*f.CreatedAfter))
+	}
+	if f.CreatedBefore != nil {
+		q.where("created_at < " + q.arg(*f.CreatedBefore))
+	}
+	if f.UpdatedSince != nil {
+		q.where("COALESCE(updated_at, created_at) >= " + q.arg(*f.UpdatedSince))
+	}
+	if len(f.Tags) > 0 {
+		tagged := `SELECT bt.blog_id FROM blog_tags bt JOIN tags t ON t.id = bt.tag_id
+				   WHERE t.slug = ANY(` + q.arg(pq.Array(f.Tags)) + `)`
+		if f.MatchAllTags {
+			tagged += ` GROUP BY bt.blog_id HAVING count(*) = ` + q.arg(len(f.Tags))
+		}
+		q.where("id IN (" + tagged + ")")
+	}
+
+	if page.After != nil {
+		if !page.After.resumes(blogsKind, name, 0) {
+			return ErrInvalidCursor
+		}
+		var key any = page.After.Time
+		if spec.text {
+			key = page.After.Text
+		}
+		op := ">"
+		if spec.desc {
+			op = "<"
+		}
+		q.where(fmt.Sprintf("(%s, id) %s (%s, %s)", spec.expr, op, q.arg(key), q.arg(page.After.ID)))
+	}
+	return nil
+}
+
+// orderBy renders the ORDER BY clause for the filter's ordering
+func (f BlogFilter) orderBy() string {
+	_, spec := f.sortSpec()
+	if spec.desc {
+		return fmt.Sprintf(" ORDER BY %s DESC, id DESC", spec.expr)
+	}
+	return fmt.Sprintf(" ORDER BY %s ASC, id ASC", spec.expr)
+}
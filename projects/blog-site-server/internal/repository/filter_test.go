@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"blog-app/internal/models"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestBlogFilterApply(t *testing.T) {
+	author := int64(4)
+	since := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	after := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		filter    BlogFilter
+		page      Page
+		wantWhere string
+		wantOrder string
+		wantArgs  int
+		wantErr   error
+	}{
+		{
+			name:      "no filter",
+			wantOrder: " ORDER BY created_at DESC, id DESC",
+		},
+		{
+			name:      "author and dates",
+			filter:    BlogFilter{AuthorID: &author, CreatedAfter: &since, UpdatedSince: &since},
+			wantWhere: " WHERE author_id = $1 AND created_at > $2 AND COALESCE(updated_at, created_at) >= $3",
+			wantOrder: " ORDER BY created_at DESC, id DESC",
+			wantArgs:  3,
+		},
+		{
+			name:   "all tags",
+			filter: BlogFilter{Tags: []string{"go", "sql"}, MatchAllTags: true},
+			wantWhere: ` WHERE id IN (SELECT bt.blog_id FROM blog_tags bt JOIN tags t ON t.id = bt.tag_id
+				   WHERE t.slug = ANY($1) GROUP BY bt.blog_id HAVING count(*) = $2)`,
+			wantOrder: " ORDER BY created_at DESC, id DESC",
+			wantArgs:  2,
+		},
+		{
+			name:      "ascending title after cursor",
+			filter:    BlogFilter{Sort: "title"},
+			page:      Page{After: &Cursor{Kind: blogsKind, Sort: "title", Text: "m", ID: 9}},
+			wantWhere: " WHERE (title, id) > ($1, $2)",
+			wantOrder: " ORDER BY title ASC, id ASC",
+			wantArgs:  2,
+		},
+		{
+			name:      "descending updated after cursor",
+			filter:    BlogFilter{Sort: "-updated_at", AuthorID: &author},
+			page:      Page{After: &Cursor{Kind: blogsKind, Sort: "-updated_at", Time: after, ID: 9}},
+			wantWhere: " WHERE author_id = $1 AND (COALESCE(updated_at, created_at), id) < ($2, $3)",
+			wantOrder: " ORDER BY COALESCE(updated_at, created_at) DESC, id DESC",
+			wantArgs:  3,
+		},
+		{
+			name:    "cursor of another ordering",
+			filter:  BlogFilter{Sort: "title"},
+			page:    Page{After: &Cursor{Kind: blogsKind, Sort: "-title", Text: "m", ID: 9}},
+			wantErr: ErrInvalidCursor,
+		},
+		{
+			name:      "unknown sort falls back to the default",
+			filter:    BlogFilter{Sort: "id; DROP TABLE blogs"},
+			wantOrder: " ORDER BY created_at DESC, id DESC",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &queryBuilder{}
+			err := tt.filter.apply(q, tt.page)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("apply = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := q.whereClause(); got != tt.wantWhere {
+				t.Errorf("whereClause = %q, want %q", got, tt.wantWhere)
+			}
+			if got := tt.filter.orderBy(); got != tt.wantOrder {
+				t.Errorf("orderBy = %q, want %q", got, tt.wantOrder)
+			}
+			if len(q.args) != tt.wantArgs {
+				t.Errorf("got %d args, want %d", len(q.args), tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestBlogFilterMatches(t *testing.T) {
+	created := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	updated := time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)
+	blog := &models.Blog{AuthorID: 4, CreatedAt: created, UpdatedAt: &updated, Tags: []string{"go", "sql"}}
+
+	author, other := int64(4), int64(5)
+	before, after := created.Add(-time.Hour), created.Add(time.Hour)
+	later := updated.Add(time.Second)
+
+	tests := []struct {
+		name   string
+		filter BlogFilter
+		want   bool
+	}{
+		{"no filter", BlogFilter{}, true},
+		{"author", BlogFilter{AuthorID: &author}, true},
+		{"other author", BlogFilter{AuthorID: &other}, false},
+		{"created after", BlogFilter{CreatedAfter: &before}, true},
+		{"created after is exclusive", BlogFilter{CreatedAfter: &created}, false},
+		{"created before", BlogFilter{CreatedBefore: &after}, true},
+		{"created before is exclusive", BlogFilter{CreatedBefore: &created}, false},
+		{"updated since is inclusive", BlogFilter{UpdatedSince: &updated}, true},
+		{"updated since later", BlogFilter{UpdatedSince: &later}, false},
+		{"any tag", BlogFilter{Tags: []string{"rust", "go"}}, true},
+		{"no tag", BlogFilter{Tags: []string{"rust"}}, false},
+		{"all tags", BlogFilter{Tags: []string{"go", "sql"}, MatchAllTags: true}, true},
+		{"not all tags", BlogFilter{Tags: []string{"go", "rust"}, MatchAllTags: true}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.matches(blog); got != tt.want {
+				t.Errorf("matches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
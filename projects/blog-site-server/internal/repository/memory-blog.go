@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"blog-app/internal/models"
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+)
+
+type MemoryBlogRepository struct {
+	db *MemoryDB
+}
+
+func NewMemoryBlogRepository(db *MemoryDB) *MemoryBlogRepository {
+	return &MemoryBlogRepository{db: db}
+}
+
+// Create inserts a new blog post into the in-memory store
+func (r *MemoryBlogRepository) Create(ctx context.Context, blog *models.Blog) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.users[blog.AuthorID]; !ok {
+		return foreignKeyViolation("blogs", "blogs_author_id_fkey")
+	}
+
+	r.db.nextBlogID++
+	blog.ID = r.db.nextBlogID
+	blog.Version = 1
+
+	now := time.Now()
+	stored := *blog
+	stored.CreatedAt = now
+	stored.UpdatedAt = &now
+	r.db.setBlogTagsLocked(&stored, blog.Tags, now)
+	r.db.blogs[stored.ID] = &stored
+	*blog = stored
+	return nil
+}
+
+// GetByID retrieves a blog post by its ID
+func (r *MemoryBlogRepository) GetByID(ctx context.Context, id int64) (*models.Blog, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	stored, ok := r.db.blogs[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	blog := *stored
+	return &blog, nil
+}
+
+// GetAll retrieves one page of blog posts matching the filter, in the
+// filter's order
+func (r *MemoryBlogRepository) GetAll(ctx context.Context, filter BlogFilter, page Page) ([]*models.Blog, *Cursor, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	name, spec := filter.sortSpec()
+	if !page.After.resumes(blogsKind, name, 0) {
+		return nil, nil, ErrInvalidCursor
+	}
+
+	var blogs []*models.Blog
+	for _, stored := range r.db.blogs {
+		if !filter.matches(stored) {
+			continue
+		}
+		if page.After != nil {
+			t, text := spec.key(stored)
+			if spec.compareKeys(t, text, stored.ID, page.After.Time, page.After.Text, page.After.ID) <= 0 {
+				continue
+			}
+		}
+		blog := *stored
+		blogs = append(blogs, &blog)
+	}
+	sort.Slice(blogs, func(i, j int) bool {
+		return spec.compare(blogs[i], blogs[j]) < 0
+	})
+
+	limit := page.limit()
+	if len(blogs) > limit+1 {
+		blogs = blogs[:limit+1]
+	}
+	blogs, next := nextPage(blogs, limit, func(blog *models.Blog) Cursor {
+		return spec.cursor(name, blog)
+	})
+	return blogs, next, nil
+}
+
+// Search retrieves one page of blog posts matching the query, best match
+// first. Matching is a simplified, unstemmed version of the Postgres
+// full-text search with the same title-over-content weighting.
+func (r *MemoryBlogRepository) Search(ctx context.Context, search SearchQuery, page Page) ([]*models.BlogSearchResult, *Cursor, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	if !page.After.resumes(searchKind, searchSort, 0) {
+		return nil, nil, ErrInvalidCursor
+	}
+
+	var results []*models.BlogSearchResult
+	for _, stored := range r.db.blogs {
+		titleSpans := wordSpans(stored.Title)
+		contentSpans := wordSpans(stored.Content)
+		titleMarked, inTitle := search.matchSpans(titleSpans)
+		contentMarked, inContent := search.matchSpans(contentSpans)
+
+		// Weights mirror setweight A (1.0) for the title and B (0.4) for content
+		rank := 0.0
+		matched := true
+		for i := range search.Terms {
+			if !inTitle[i] && !inContent[i] {
+				matched = false
+				break
+			}
+			if inTitle[i] {
+				rank += 1.0
+			}
+			if inContent[i] {
+				rank += 0.4
+			}
+		}
+		if !matched {
+			continue
+		}
+		if page.After != nil && (rank > page.After.Rank || (rank == page.After.Rank && stored.ID >= page.After.ID)) {
+			continue
+		}
+
+		results = append(results, &models.BlogSearchResult{
+			Blog:           *stored,
+			Rank:           rank,
+			TitleHighlight: renderHighlight(highlightSpans(stored.Title, titleSpans, titleMarked, 0)),
+			Snippet:        renderHighlight(highlightSpans(stored.Content, contentSpans, contentMarked, 30)),
+		})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		return results[i].ID > results[j].ID
+	})
+
+	limit := page.limit()
+	if len(results) > limit+1 {
+		results = results[:limit+1]
+	}
+	results, next := nextPage(results, limit, searchCursor)
+	return results, next, nil
+}
+
+// Update updates an existing blog post if its stored version is ifVersion
+// and fills in blog with the stored row
+func (r *MemoryBlogRepository) Update(ctx context.Context, blog *models.Blog, ifVersion int64) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	stored, ok := r.db.blogs[blog.ID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if !versionMatches(stored.Version, ifVersion) {
+		return ErrVersionConflict
+	}
+	now := time.Now()
+	stored.Title = blog.Title
+	stored.Content = blog.Content
+	stored.CoverImage = blog.CoverImage
+	r.db.setBlogTagsLocked(stored, blog.Tags, now)
+	stored.UpdatedAt = &now
+	stored.Version++
+	*blog = *stored
+	return nil
+}
+
+// Patch applies a merge patch if the stored version is ifVersion and
+// returns the stored row
+func (r *MemoryBlogRepository) Patch(ctx context.Context, id int64, patch *models.BlogPatch, ifVersion int64) (*models.Blog, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	stored, ok := r.db.blogs[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	if !versionMatches(stored.Version, ifVersion) {
+		return nil, ErrVersionConflict
+	}
+	if !patch.Empty() {
+		now := time.Now()
+		patch.ApplyTo(stored, now)
+		if patch.Tags.Set {
+			r.db.setBlogTagsLocked(stored, patch.Tags.Value, now)
+		}
+		stored.Version++
+	}
+	blog := *stored
+	return &blog, nil
+}
+
+// Delete deletes a blog post by its ID if its stored version is ifVersion
+func (r *MemoryBlogRepository) Delete(ctx context.Context, id, ifVersion int64) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	stored, ok := r.db.blogs[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if !versionMatches(stored.Version, ifVersion) {
+		return ErrVersionConflict
+	}
+	r.db.deleteBlogLocked(id)
+	return nil
+}
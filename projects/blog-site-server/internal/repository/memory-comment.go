@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"blog-app/internal/models"
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+)
+
+type MemoryCommentRepository struct {
+	db *MemoryDB
+}
+
+func NewMemoryCommentRepository(db *MemoryDB) *MemoryCommentRepository {
+	return &MemoryCommentRepository{db: db}
+}
+
+// Create inserts a new comment into the in-memory store
+func (r *MemoryCommentRepository) Create(ctx context.Context, comment *models.Comment) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.blogs[comment.PostID]; !ok {
+		return foreignKeyViolation("comments", "comments_post_id_fkey")
+	}
+	if _, ok := r.db.users[comment.UserID]; !ok {
+		return foreignKeyViolation("comments", "comments_user_id_fkey")
+	}
+	if comment.ParentID != nil {
+		parent, ok := r.db.comments[*comment.ParentID]
+		if !ok || parent.PostID != comment.PostID {
+			return foreignKeyViolation("comments", "comments_parent_fkey")
+		}
+	}
+
+	r.db.nextCommentID++
+	comment.ID = r.db.nextCommentID
+	comment.Version = 1
+
+	now := time.Now()
+	stored := *comment
+	stored.Deleted = false
+	stored.Replies = nil
+	stored.HasMoreReplies = false
+	stored.CreatedAt = now
+	stored.UpdatedAt = now
+	r.db.comments[stored.ID] = &stored
+	*comment = stored
+	return nil
+}
+
+// GetByID retrieves a comment by its ID
+func (r *MemoryCommentRepository) GetByID(ctx context.Context, id int64) (*models.Comment, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	stored, ok := r.db.comments[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	comment := *stored
+	return &comment, nil
+}
+
+// GetByBlogID retrieves one page of comments for a specific blog post,
+// newest first
+func (r *MemoryCommentRepository) GetByBlogID(ctx context.Context, blogID int64, page Page) ([]*models.Comment, *Cursor, error) {
+	return r.list(ctx, blogID, false, page)
+}
+
+// GetRootsByBlogID retrieves one page of top-level comments for a specific
+// blog post, newest first
+func (r *MemoryCommentRepository) GetRootsByBlogID(ctx context.Context, blogID int64, page Page) ([]*models.Comment, *Cursor, error) {
+	return r.list(ctx, blogID, true, page)
+}
+
+// list pages through the comments of a blog post
+func (r *MemoryCommentRepository) list(ctx context.Context, blogID int64, rootsOnly bool, page Page) ([]*models.Comment, *Cursor, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	kind := commentsKind
+	if rootsOnly {
+		kind = commentRootsKind
+	}
+	if !page.After.resumes(kind, newestFirstSort, blogID) {
+		return nil, nil, ErrInvalidCursor
+	}
+
+	var comments []*models.Comment
+	for _, stored := range r.db.comments {
+		if stored.PostID != blogID || !page.After.admits(stored.CreatedAt, stored.ID) {
+			continue
+		}
+		if rootsOnly && stored.ParentID != nil {
+			continue
+		}
+		comment := *stored
+		comments = append(comments, &comment)
+	}
+	sort.Slice(comments, func(i, j int) bool {
+		return newerFirst(comments[i].CreatedAt, comments[i].ID, comments[j].CreatedAt, comments[j].ID)
+	})
+
+	limit := page.limit()
+	if len(comments) > limit+1 {
+		comments = comments[:limit+1]
+	}
+	comments, next := nextPage(comments, limit, func(comment *models.Comment) Cursor {
+		return commentCursor(kind, comment)
+	})
+	return comments, next, nil
+}
+
+// GetReplies retrieves the replies below the given comments, oldest first,
+// down to maxDepth levels plus one extra level to detect truncation
+func (r *MemoryCommentRepository) GetReplies(ctx context.Context, parentIDs []int64, maxDepth int) ([]*models.Comment, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	var replies []*models.Comment
+	level := parentIDs
+	for depth := 1; depth <= maxDepth+1 && len(level) > 0; depth++ {
+		parents := make(map[int64]bool, len(level))
+		for _, id := range level {
+			parents[id] = true
+		}
+		level = nil
+		for _, stored := range r.db.comments {
+			if stored.ParentID != nil && parents[*stored.ParentID] {
+				comment := *stored
+				replies = append(replies, &comment)
+				level = append(level, comment.ID)
+			}
+		}
+	}
+
+	sort.Slice(replies, func(i, j int) bool {
+		return newerFirst(replies[j].CreatedAt, replies[j].ID, replies[i].CreatedAt, replies[i].ID)
+	})
+	return replies, nil
+}
+
+// Update updates an existing comment if its stored version is ifVersion
+// and fills in comment with the stored row
+func (r *MemoryCommentRepository) Update(ctx context.Context, comment *models.Comment, ifVersion int64) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	stored, ok := r.db.comments[comment.ID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if !versionMatches(stored.Version, ifVersion) {
+		return ErrVersionConflict
+	}
+	stored.Content = comment.Content
+	stored.UpdatedAt = time.Now()
+	stored.Version++
+	*comment = *stored
+	return nil
+}
+
+// Patch applies a merge patch if the stored version is ifVersion and
+// returns the stored row
+func (r *MemoryCommentRepository) Patch(ctx context.Context, id int64, patch *models.CommentPatch, ifVersion int64) (*models.Comment, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	stored, ok := r.db.comments[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	if !versionMatches(stored.Version, ifVersion) {
+		return nil, ErrVersionConflict
+	}
+	if !patch.Empty() {
+		patch.ApplyTo(stored, time.Now())
+		stored.Version++
+	}
+	comment := *stored
+	return &comment, nil
+}
+
+// Delete deletes a comment by its ID, keeping a "[deleted]" placeholder
+// when it still has replies and pruning placeholders left without any. A
+// placeholder that still has replies is left untouched. Only the comment
+// itself is checked against ifVersion.
+func (r *MemoryCommentRepository) Delete(ctx context.Context, id, ifVersion int64) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	stored, ok := r.db.comments[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if !versionMatches(stored.Version, ifVersion) {
+		return ErrVersionConflict
+	}
+
+	for {
+		if r.db.hasRepliesLocked(id) {
+			if stored.Deleted {
+				return nil
+			}
+			stored.Content = models.DeletedCommentContent
+			stored.Deleted = true
+			stored.UpdatedAt = time.Now()
+			stored.Version++
+			return nil
+		}
+
+		r.db.deleteCommentLocked(id)
+		if stored.ParentID == nil {
+			return nil
+		}
+		parent, ok := r.db.comments[*stored.ParentID]
+		if !ok || !parent.Deleted {
+			return nil
+		}
+		id, stored = parent.ID, parent
+	}
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"blog-app/internal/models"
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestMemoryCommentDelete(t *testing.T) {
+	ctx := context.Background()
+	db := NewMemoryDB()
+	comments := NewMemoryCommentRepository(db)
+	if err := NewMemoryUserRepository(db).Create(ctx, &models.User{Username: "author", Email: "author@example.com"}); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if err := NewMemoryBlogRepository(db).Create(ctx, &models.Blog{Title: "Post", AuthorID: 1}); err != nil {
+		t.Fatalf("create blog: %v", err)
+	}
+
+	create := func(parentID *int64) int64 {
+		t.Helper()
+		comment := &models.Comment{PostID: 1, UserID: 1, ParentID: parentID, Content: "comment"}
+		if err := comments.Create(ctx, comment); err != nil {
+			t.Fatalf("create comment: %v", err)
+		}
+		return comment.ID
+	}
+	get := func(id int64) *models.Comment {
+		t.Helper()
+		comment, err := comments.GetByID(ctx, id)
+		if err != nil {
+			t.Fatalf("get comment %d: %v", id, err)
+		}
+		return comment
+	}
+
+	root := create(nil)
+	first := create(&root)
+	second := create(&root)
+
+	// A comment with replies becomes a placeholder
+	if err := comments.Delete(ctx, root, 1); err != nil {
+		t.Fatalf("delete root: %v", err)
+	}
+	placeholder := get(root)
+	if !placeholder.Deleted || placeholder.Content != models.DeletedCommentContent || placeholder.Version != 2 {
+		t.Fatalf("root = %+v, want a placeholder at version 2", placeholder)
+	}
+
+	// Deleting the placeholder again changes nothing
+	if err := comments.Delete(ctx, root, 2); err != nil {
+		t.Fatalf("delete placeholder: %v", err)
+	}
+	if got := get(root).Version; got != 2 {
+		t.Errorf("placeholder version = %d after a second delete, want 2", got)
+	}
+
+	// Neither does removing one of several replies
+	if err := comments.Delete(ctx, first, 1); err != nil {
+		t.Fatalf("delete first reply: %v", err)
+	}
+	if got := get(root).Version; got != 2 {
+		t.Errorf("placeholder version = %d after a reply was deleted, want 2", got)
+	}
+
+	// Removing the last reply prunes the placeholder
+	if err := comments.Delete(ctx, second, 1); err != nil {
+		t.Fatalf("delete second reply: %v", err)
+	}
+	if _, err := comments.GetByID(ctx, root); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("get pruned placeholder = %v, want sql.ErrNoRows", err)
+	}
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"blog-app/internal/models"
+	"context"
+	"database/sql"
+	"time"
+)
+
+type MemorySessionRepository struct {
+	db *MemoryDB
+}
+
+func NewMemorySessionRepository(db *MemoryDB) *MemorySessionRepository {
+	return &MemorySessionRepository{db: db}
+}
+
+// Create inserts a new login session
+func (r *MemorySessionRepository) Create(ctx context.Context, session *models.Session) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.users[session.UserID]; !ok {
+		return foreignKeyViolation("sessions", "sessions_user_id_fkey")
+	}
+	if _, ok := r.db.sessions[session.TokenHash]; ok {
+		return uniqueViolation("sessions", "sessions_pkey")
+	}
+
+	session.CreatedAt = time.Now()
+	stored := *session
+	r.db.sessions[stored.TokenHash] = &stored
+	return nil
+}
+
+// GetByTokenHash retrieves an unexpired session by its token hash
+func (r *MemorySessionRepository) GetByTokenHash(ctx context.Context, tokenHash string) (*models.Session, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	stored, ok := r.db.sessions[tokenHash]
+	if !ok || !stored.ExpiresAt.After(time.Now()) {
+		return nil, sql.ErrNoRows
+	}
+	session := *stored
+	return &session, nil
+}
+
+// Delete removes a session by its token hash
+func (r *MemorySessionRepository) Delete(ctx context.Context, tokenHash string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	delete(r.db.sessions, tokenHash)
+	return nil
+}
+
+// DeleteByUser removes every session of a user except the one with
+// exceptTokenHash, which may be empty to remove them all
+func (r *MemorySessionRepository) DeleteByUser(ctx context.Context, userID int64, exceptTokenHash string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for tokenHash, session := range r.db.sessions {
+		if session.UserID == userID && tokenHash != exceptTokenHash {
+			delete(r.db.sessions, tokenHash)
+		}
+	}
+	return nil
+}
+
+// DeleteExpired removes every expired session
+func (r *MemorySessionRepository) DeleteExpired(ctx context.Context) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	now := time.Now()
+	for tokenHash, session := range r.db.sessions {
+		if !session.ExpiresAt.After(now) {
+			delete(r.db.sessions, tokenHash)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"blog-app/internal/models"
+	"context"
+	"database/sql"
+	"sort"
+)
+
+type MemoryTagRepository struct {
+	db *MemoryDB
+}
+
+func NewMemoryTagRepository(db *MemoryDB) *MemoryTagRepository {
+	return &MemoryTagRepository{db: db}
+}
+
+// GetBySlug retrieves a tag by its normalized slug
+func (r *MemoryTagRepository) GetBySlug(ctx context.Context, slug string) (*models.Tag, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	stored, ok := r.db.tags[slug]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	tag := *stored
+	tag.PostCount = r.db.postCountsLocked()[slug]
+	return &tag, nil
+}
+
+// GetAll retrieves one page of the tags carrying at least one blog post,
+// ordered by slug
+func (r *MemoryTagRepository) GetAll(ctx context.Context, page Page) ([]*models.Tag, *Cursor, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	if !page.After.resumes(tagsKind, tagSort, 0) {
+		return nil, nil, ErrInvalidCursor
+	}
+
+	var tags []*models.Tag
+	for slug, count := range r.db.postCountsLocked() {
+		if page.After != nil && slug <= page.After.Text {
+			continue
+		}
+		tag := *r.db.tags[slug]
+		tag.PostCount = count
+		tags = append(tags, &tag)
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].Slug < tags[j].Slug
+	})
+
+	limit := page.limit()
+	if len(tags) > limit+1 {
+		tags = tags[:limit+1]
+	}
+	tags, next := nextPage(tags, limit, tagCursor)
+	return tags, next, nil
+}
+
+// postCountsLocked counts the blog posts per tag slug, leaving out unused
+// tags. The caller must hold the lock.
+func (m *MemoryDB) postCountsLocked() map[string]int64 {
+	counts := make(map[string]int64)
+	for _, blog := range m.blogs {
+		for _, slug := range blog.Tags {
+			counts[slug]++
+		}
+	}
+	return counts
+}
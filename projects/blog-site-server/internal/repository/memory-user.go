@@ -0,0 +1,207 @@
+package repository
+
+import (
+	"blog-app/internal/models"
+	"context"
+	"database/sql"
+	"sort"
+	"time"
+)
+
+type MemoryUserRepository struct {
+	db *MemoryDB
+}
+
+func NewMemoryUserRepository(db *MemoryDB) *MemoryUserRepository {
+	return &MemoryUserRepository{db: db}
+}
+
+// checkUniqueLocked enforces the username and email unique constraints.
+// The caller must hold the lock.
+func (r *MemoryUserRepository) checkUniqueLocked(user *models.User) error {
+	for id, existing := range r.db.users {
+		if id == user.ID {
+			continue
+		}
+		if existing.Username == user.Username {
+			return uniqueViolation("users", "users_username_key")
+		}
+		if existing.Email == user.Email {
+			return uniqueViolation("users", "users_email_key")
+		}
+	}
+	return nil
+}
+
+// Create inserts a new user into the in-memory store
+func (r *MemoryUserRepository) Create(ctx context.Context, user *models.User) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	user.ID = 0
+	if err := r.checkUniqueLocked(user); err != nil {
+		return err
+	}
+
+	r.db.nextUserID++
+	user.ID = r.db.nextUserID
+	user.Version = 1
+
+	now := time.Now()
+	stored := *user
+	stored.CreatedAt = now
+	stored.UpdatedAt = now
+	stored.IsActive = true
+	r.db.users[stored.ID] = &stored
+	*user = stored
+	return nil
+}
+
+// GetByID retrieves a user by their ID
+func (r *MemoryUserRepository) GetByID(ctx context.Context, id int64) (*models.User, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	stored, ok := r.db.users[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	user := *stored
+	return &user, nil
+}
+
+// GetByUsername retrieves a user by their username
+func (r *MemoryUserRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	for _, stored := range r.db.users {
+		if stored.Username == username {
+			user := *stored
+			return &user, nil
+		}
+	}
+	return nil, sql.ErrNoRows
+}
+
+// GetAll retrieves one page of users, newest first
+func (r *MemoryUserRepository) GetAll(ctx context.Context, page Page) ([]*models.User, *Cursor, error) {
+	r.db.mu.RLock()
+	defer r.db.mu.RUnlock()
+
+	if !page.After.resumes(usersKind, newestFirstSort, 0) {
+		return nil, nil, ErrInvalidCursor
+	}
+
+	var users []*models.User
+	for _, stored := range r.db.users {
+		if !page.After.admits(stored.CreatedAt, stored.ID) {
+			continue
+		}
+		user := *stored
+		// The Postgres listing does not select password hashes either
+		user.PasswordHash = ""
+		users = append(users, &user)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		return newerFirst(users[i].CreatedAt, users[i].ID, users[j].CreatedAt, users[j].ID)
+	})
+
+	limit := page.limit()
+	if len(users) > limit+1 {
+		users = users[:limit+1]
+	}
+	users, next := nextPage(users, limit, userCursor)
+	return users, next, nil
+}
+
+// Update updates an existing user if their stored version is ifVersion and
+// fills in user with the stored row
+func (r *MemoryUserRepository) Update(ctx context.Context, user *models.User, ifVersion int64) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	stored, ok := r.db.users[user.ID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if !versionMatches(stored.Version, ifVersion) {
+		return ErrVersionConflict
+	}
+	candidate := *stored
+	candidate.Email = user.Email
+	if err := r.checkUniqueLocked(&candidate); err != nil {
+		return err
+	}
+
+	stored.FullName = user.FullName
+	stored.Email = user.Email
+	stored.Role = user.Role
+	stored.Bio = user.Bio
+	stored.AvatarURL = user.AvatarURL
+	stored.UpdatedAt = time.Now()
+	stored.IsActive = user.IsActive
+	stored.Version++
+	*user = *stored
+	return nil
+}
+
+// Patch applies a merge patch if the stored version is ifVersion and
+// returns the stored row
+func (r *MemoryUserRepository) Patch(ctx context.Context, id int64, patch *models.UserPatch, ifVersion int64) (*models.User, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	stored, ok := r.db.users[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+	if !versionMatches(stored.Version, ifVersion) {
+		return nil, ErrVersionConflict
+	}
+	if patch.Empty() {
+		user := *stored
+		return &user, nil
+	}
+
+	candidate := *stored
+	patch.ApplyTo(&candidate, time.Now())
+	if err := r.checkUniqueLocked(&candidate); err != nil {
+		return nil, err
+	}
+	candidate.Version++
+	*stored = candidate
+	user := candidate
+	return &user, nil
+}
+
+// UpdatePassword replaces a user's password hash
+func (r *MemoryUserRepository) UpdatePassword(ctx context.Context, id int64, passwordHash string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	stored, ok := r.db.users[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	stored.PasswordHash = passwordHash
+	stored.UpdatedAt = time.Now()
+	stored.Version++
+	return nil
+}
+
+// Delete deletes a user by their ID if their stored version is ifVersion
+func (r *MemoryUserRepository) Delete(ctx context.Context, id, ifVersion int64) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	stored, ok := r.db.users[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if !versionMatches(stored.Version, ifVersion) {
+		return ErrVersionConflict
+	}
+	r.db.deleteUserLocked(id)
+	return nil
+}
@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"blog-app/internal/models"
+	"sync"
+	"time"
+)
+
+// MemoryDB holds the tables backing the in-memory repositories. A single
+// lock guards all tables so that cross-table rules (foreign keys, cascading
+// deletes) behave like they do in Postgres.
+type MemoryDB struct {
+	mu sync.RWMutex
+
+	blogs    map[int64]*models.Blog
+	users    map[int64]*models.User
+	comments map[int64]*models.Comment
+	sessions map[string]*models.Session
+	// tags is keyed by slug; blogs hold the slugs of their tags
+	tags map[string]*models.Tag
+
+	nextBlogID    int64
+	nextUserID    int64
+	nextCommentID int64
+	nextTagID     int64
+}
+
+// NewMemoryDB creates an empty in-memory database
+func NewMemoryDB() *MemoryDB {
+	return &MemoryDB{
+		blogs:    make(map[int64]*models.Blog),
+		users:    make(map[int64]*models.User),
+		comments: make(map[int64]*models.Comment),
+		sessions: make(map[string]*models.Session),
+		tags:     make(map[string]*models.Tag),
+	}
+}
+
+// uniqueViolation mirrors the error classify reports for a duplicate key
+func uniqueViolation(table, constraint string) error {
+	return newConstraintError(ErrDuplicate, table, constraint)
+}
+
+// foreignKeyViolation mirrors the error classify reports for a missing
+// reference
+func foreignKeyViolation(table, constraint string) error {
+	return newConstraintError(ErrMissingReference, table, constraint)
+}
+
+// newerFirst orders rows by created_at DESC, id DESC
+func newerFirst(aCreated time.Time, aID int64, bCreated time.Time, bID int64) bool {
+	if !aCreated.Equal(bCreated) {
+		return aCreated.After(bCreated)
+	}
+	return aID > bID
+}
+
+// setBlogTagsLocked replaces the tags of a stored blog, creating tags seen
+// for the first time. slugs must be normalized. The caller must hold the
+// write lock.
+func (m *MemoryDB) setBlogTagsLocked(blog *models.Blog, slugs []string, now time.Time) {
+	for _, slug := range slugs {
+		if _, ok := m.tags[slug]; !ok {
+			m.nextTagID++
+			m.tags[slug] = &models.Tag{ID: m.nextTagID, Slug: slug, CreatedAt: now}
+		}
+	}
+	blog.Tags = append([]string{}, slugs...)
+}
+
+// deleteBlogLocked removes a blog and cascades to its comments. The caller
+// must hold the write lock.
+func (m *MemoryDB) deleteBlogLocked(id int64) {
+	delete(m.blogs, id)
+	for commentID, comment := range m.comments {
+		if comment.PostID == id {
+			delete(m.comments, commentID)
+		}
+	}
+}
+
+// deleteCommentLocked removes a comment and cascades to its replies. The
+// caller must hold the write lock.
+func (m *MemoryDB) deleteCommentLocked(id int64) {
+	delete(m.comments, id)
+	for replyID, reply := range m.comments {
+		if reply.ParentID != nil && *reply.ParentID == id {
+			m.deleteCommentLocked(replyID)
+		}
+	}
+}
+
+// hasRepliesLocked reports whether any comment replies to id. The caller
+// must hold the lock.
+func (m *MemoryDB) hasRepliesLocked(id int64) bool {
+	for _, reply := range m.comments {
+		if reply.ParentID != nil && *reply.ParentID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// deleteUserLocked removes a user and cascades to their blogs, comments and
+// sessions. The caller must hold the write lock.
+func (m *MemoryDB) deleteUserLocked(id int64) {
+	delete(m.users, id)
+	for blogID, blog := range m.blogs {
+		if blog.AuthorID == id {
+			m.deleteBlogLocked(blogID)
+		}
+	}
+	for commentID, comment := range m.comments {
+		if comment.UserID == id {
+			m.deleteCommentLocked(commentID)
+		}
+	}
+	for tokenHash, session := range m.sessions {
+		if session.UserID == id {
+			delete(m.sessions, tokenHash)
+		}
+	}
+}
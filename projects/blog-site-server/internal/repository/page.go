@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+const (
+	// DefaultPageSize is used when the client does not ask for a limit
+	DefaultPageSize = 20
+	// MaxPageSize caps every listing regardless of the requested limit
+	MaxPageSize = 100
+)
+
+// ErrInvalidCursor is returned when a pagination cursor cannot be decoded
+// or was issued by another listing
+var ErrInvalidCursor = errors.New("invalid pagination cursor")
+
+// Cursor identifies the last row of a page by its sort key and id, along
+// with the listing, ordering and scope it was issued for
+type Cursor struct {
+	Kind  string    `json:"k,omitempty"`
+	Sort  string    `json:"s,omitempty"`
+	Scope int64     `json:"p,omitempty"`
+	Time  time.Time `json:"t,omitzero"`
+	Text  string    `json:"x,omitempty"`
+	Rank  float64   `json:"r,omitempty"`
+	ID    int64     `json:"id"`
+}
+
+// Page requests one slice of a listing. After is nil for the first page.
+type Page struct {
+	Limit int
+	After *Cursor
+}
+
+// EncodeCursor turns a cursor into the opaque token handed to clients
+func EncodeCursor(c *Cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a token produced by EncodeCursor
+func DecodeCursor(token string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID <= 0 {
+		return nil, ErrInvalidCursor
+	}
+	return &c, nil
+}
+
+// limit returns the effective page size, clamped to MaxPageSize
+func (p Page) limit() int {
+	switch {
+	case p.Limit <= 0:
+		return DefaultPageSize
+	case p.Limit > MaxPageSize:
+		return MaxPageSize
+	}
+	return p.Limit
+}
+
+// nextPage trims rows fetched with limit+1 down to the page and returns the
+// cursor for the following page, or nil when this is the last one
+func nextPage[T any](rows []T, limit int, cursorOf func(T) Cursor) ([]T, *Cursor) {
+	if len(rows) <= limit {
+		return rows, nil
+	}
+	rows = rows[:limit]
+	next := cursorOf(rows[len(rows)-1])
+	return rows, &next
+}
+
+// Listing kinds recorded in cursors
+const (
+	blogsKind        = "blogs"
+	searchKind       = "search"
+	usersKind        = "users"
+	commentsKind     = "comments"
+	commentRootsKind = "comment-roots"
+	tagsKind         = "tags"
+)
+
+// resumes reports whether a listing of the given kind, ordering and scope
+// may continue from the cursor. A nil cursor starts any listing.
+func (c *Cursor) resumes(kind, sort string, scope int64) bool {
+	return c == nil || (c.Kind == kind && c.Sort == sort && c.Scope == scope)
+}
+
+// newestFirstSort is the Sort of cursors issued by listings ordered by
+// created_at DESC, id DESC
+const newestFirstSort = "-created_at"
+
+// admits reports whether a row sorts after the cursor in
+// created_at DESC, id DESC order, i.e. belongs on a later page
+func (c *Cursor) admits(createdAt time.Time, id int64) bool {
+	if c == nil {
+		return true
+	}
+	if !createdAt.Equal(c.Time) {
+		return createdAt.Before(c.Time)
+	}
+	return id < c.ID
+}
@@ -0,0 +1,265 @@
+package repository
+
+import (
+	"blog-app/internal/models"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []Cursor{
+		{Kind: usersKind, Sort: newestFirstSort, Time: time.Date(2024, 5, 1, 12, 30, 0, 123456789, time.UTC), ID: 7},
+		{Kind: commentsKind, Sort: newestFirstSort, Scope: 3, Time: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), ID: 9},
+		{Kind: blogsKind, Sort: "title", Text: "Hello, world", ID: 1},
+		{Kind: searchKind, Sort: searchSort, Rank: 1.4, ID: 42},
+	}
+	for _, want := range tests {
+		t.Run(want.Kind, func(t *testing.T) {
+			got, err := DecodeCursor(EncodeCursor(&want))
+			if err != nil {
+				t.Fatalf("DecodeCursor: %v", err)
+			}
+			if !got.Time.Equal(want.Time) {
+				t.Errorf("Time = %v, want %v", got.Time, want.Time)
+			}
+			got.Time = want.Time
+			if *got != want {
+				t.Errorf("DecodeCursor = %+v, want %+v", *got, want)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"empty", ""},
+		{"not base64", "!!!"},
+		{"padded base64", "eyJpZCI6MX0="},
+		{"not json", "bm90IGpzb24"},
+		{"json array", "WzFd"},
+		{"missing id", "e30"},
+		{"zero id", "eyJpZCI6MH0"},
+		{"negative id", "eyJpZCI6LTF9"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if c, err := DecodeCursor(tt.token); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("DecodeCursor = %+v, %v; want ErrInvalidCursor", c, err)
+			}
+		})
+	}
+}
+
+func TestCursorResumes(t *testing.T) {
+	cursor := &Cursor{Kind: commentsKind, Sort: newestFirstSort, Scope: 1, ID: 5}
+	tests := []struct {
+		name   string
+		cursor *Cursor
+		kind   string
+		sort   string
+		scope  int64
+		want   bool
+	}{
+		{"first page", nil, usersKind, newestFirstSort, 0, true},
+		{"same listing", cursor, commentsKind, newestFirstSort, 1, true},
+		{"other kind", cursor, usersKind, newestFirstSort, 1, false},
+		{"other roots listing", cursor, commentRootsKind, newestFirstSort, 1, false},
+		{"other sort", cursor, commentsKind, "created_at", 1, false},
+		{"other scope", cursor, commentsKind, newestFirstSort, 2, false},
+		{"unscoped listing", cursor, commentsKind, newestFirstSort, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cursor.resumes(tt.kind, tt.sort, tt.scope); got != tt.want {
+				t.Errorf("resumes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPageLimit(t *testing.T) {
+	tests := []struct {
+		limit int
+		want  int
+	}{
+		{0, DefaultPageSize},
+		{-1, DefaultPageSize},
+		{1, 1},
+		{MaxPageSize, MaxPageSize},
+		{MaxPageSize + 1, MaxPageSize},
+	}
+	for _, tt := range tests {
+		if got := (Page{Limit: tt.limit}).limit(); got != tt.want {
+			t.Errorf("Page{Limit: %d}.limit() = %d, want %d", tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestNextPage(t *testing.T) {
+	cursorOf := func(id int64) Cursor { return Cursor{ID: id} }
+	tests := []struct {
+		name     string
+		rows     []int64
+		limit    int
+		wantRows []int64
+		wantNext *Cursor
+	}{
+		{"empty", nil, 2, nil, nil},
+		{"short page", []int64{5}, 2, []int64{5}, nil},
+		{"exactly full", []int64{5, 4}, 2, []int64{5, 4}, nil},
+		{"more rows", []int64{5, 4, 3}, 2, []int64{5, 4}, &Cursor{ID: 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, next := nextPage(tt.rows, tt.limit, cursorOf)
+			if !reflect.DeepEqual(rows, tt.wantRows) {
+				t.Errorf("rows = %v, want %v", rows, tt.wantRows)
+			}
+			if !reflect.DeepEqual(next, tt.wantNext) {
+				t.Errorf("next = %+v, want %+v", next, tt.wantNext)
+			}
+		})
+	}
+}
+
+// pageAll follows cursors through a listing and returns the ids it yields
+func pageAll[T any](t *testing.T, limit int, list func(Page) ([]T, *Cursor, error), id func(T) int64) []int64 {
+	t.Helper()
+	var ids []int64
+	page := Page{Limit: limit}
+	for {
+		rows, next, err := list(page)
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		if len(rows) > limit {
+			t.Fatalf("got %d rows, want at most %d", len(rows), limit)
+		}
+		for _, row := range rows {
+			ids = append(ids, id(row))
+		}
+		if next == nil {
+			return ids
+		}
+		page.After = next
+	}
+}
+
+func TestMemoryListingsPageThrough(t *testing.T) {
+	ctx := context.Background()
+	db := NewMemoryDB()
+	users := NewMemoryUserRepository(db)
+	blogs := NewMemoryBlogRepository(db)
+	comments := NewMemoryCommentRepository(db)
+
+	for i := 1; i <= 5; i++ {
+		user := &models.User{Username: fmt.Sprintf("user%d", i), Email: fmt.Sprintf("user%d@example.com", i), Role: "reader"}
+		if err := users.Create(ctx, user); err != nil {
+			t.Fatalf("create user: %v", err)
+		}
+	}
+	for i := 1; i <= 2; i++ {
+		if err := blogs.Create(ctx, &models.Blog{Title: fmt.Sprintf("Post %d", i), AuthorID: 1}); err != nil {
+			t.Fatalf("create blog: %v", err)
+		}
+	}
+	for i := 0; i < 6; i++ {
+		comment := &models.Comment{PostID: int64(i%2 + 1), UserID: 1, Content: "comment"}
+		if err := comments.Create(ctx, comment); err != nil {
+			t.Fatalf("create comment: %v", err)
+		}
+	}
+
+	userIDs := pageAll(t, 2, func(page Page) ([]*models.User, *Cursor, error) {
+		return users.GetAll(ctx, page)
+	}, func(user *models.User) int64 { return user.ID })
+	if want := []int64{5, 4, 3, 2, 1}; !reflect.DeepEqual(userIDs, want) {
+		t.Errorf("users = %v, want %v", userIDs, want)
+	}
+
+	commentIDs := pageAll(t, 2, func(page Page) ([]*models.Comment, *Cursor, error) {
+		return comments.GetByBlogID(ctx, 1, page)
+	}, func(comment *models.Comment) int64 { return comment.ID })
+	if want := []int64{5, 3, 1}; !reflect.DeepEqual(commentIDs, want) {
+		t.Errorf("comments of blog 1 = %v, want %v", commentIDs, want)
+	}
+}
+
+func TestMemoryListingsRejectForeignCursors(t *testing.T) {
+	ctx := context.Background()
+	db := NewMemoryDB()
+	users := NewMemoryUserRepository(db)
+	blogs := NewMemoryBlogRepository(db)
+	comments := NewMemoryCommentRepository(db)
+	tags := NewMemoryTagRepository(db)
+
+	if err := users.Create(ctx, &models.User{Username: "author", Email: "author@example.com", Role: "author"}); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	if err := blogs.Create(ctx, &models.Blog{Title: "Post", AuthorID: 1}); err != nil {
+		t.Fatalf("create blog: %v", err)
+	}
+
+	userCursor := &Cursor{Kind: usersKind, Sort: newestFirstSort, Time: time.Now(), ID: 1}
+	blogCursor := &Cursor{Kind: blogsKind, Sort: DefaultBlogSort, Time: time.Now(), ID: 1}
+	commentCursor := &Cursor{Kind: commentsKind, Sort: newestFirstSort, Scope: 1, Time: time.Now(), ID: 1}
+	tagCursor := &Cursor{Kind: tagsKind, Sort: tagSort, Text: "go", ID: 1}
+
+	lists := map[string]func(*Cursor) error{
+		"users": func(c *Cursor) error {
+			_, _, err := users.GetAll(ctx, Page{After: c})
+			return err
+		},
+		"blogs": func(c *Cursor) error {
+			_, _, err := blogs.GetAll(ctx, BlogFilter{}, Page{After: c})
+			return err
+		},
+		"comments of blog 1": func(c *Cursor) error {
+			_, _, err := comments.GetByBlogID(ctx, 1, Page{After: c})
+			return err
+		},
+		"comments of blog 2": func(c *Cursor) error {
+			_, _, err := comments.GetByBlogID(ctx, 2, Page{After: c})
+			return err
+		},
+		"comment roots of blog 1": func(c *Cursor) error {
+			_, _, err := comments.GetRootsByBlogID(ctx, 1, Page{After: c})
+			return err
+		},
+		"tags": func(c *Cursor) error {
+			_, _, err := tags.GetAll(ctx, Page{After: c})
+			return err
+		},
+	}
+	accepts := map[string]*Cursor{
+		"users":                   userCursor,
+		"blogs":                   blogCursor,
+		"comments of blog 1":      commentCursor,
+		"comments of blog 2":      nil,
+		"comment roots of blog 1": nil,
+		"tags":                    tagCursor,
+	}
+	cursors := map[string]*Cursor{"user": userCursor, "blog": blogCursor, "comment": commentCursor, "tag": tagCursor}
+
+	for listing, list := range lists {
+		for name, cursor := range cursors {
+			t.Run(name+" cursor on "+listing, func(t *testing.T) {
+				err := list(cursor)
+				if cursor == accepts[listing] {
+					if err != nil {
+						t.Errorf("got %v, want the cursor accepted", err)
+					}
+				} else if !errors.Is(err, ErrInvalidCursor) {
+					t.Errorf("got %v, want ErrInvalidCursor", err)
+				}
+			})
+		}
+	}
+}
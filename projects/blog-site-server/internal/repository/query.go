@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// queryBuilder accumulates SET assignments, WHERE conditions and their
+// positional arguments so that client values are always bound as
+// parameters. Column names must come from code, never from the client.
+type queryBuilder struct {
+	sets  []string
+	conds []string
+	args  []any
+}
+
+// set adds a column assignment for an UPDATE
+func (q *queryBuilder) set(column string, value any) {
+	q.sets = append(q.sets, column+" = "+q.arg(value))
+}
+
+// setClause renders the accumulated assignments
+func (q *queryBuilder) setClause() string {
+	return strings.Join(q.sets, ", ")
+}
+
+// arg binds a value and returns its placeholder
+func (q *queryBuilder) arg(value any) string {
+	q.args = append(q.args, value)
+	return fmt.Sprintf("$%d", len(q.args))
+}
+
+// where adds a condition; use arg to embed values in it
+func (q *queryBuilder) where(cond string) {
+	q.conds = append(q.conds, cond)
+}
+
+// whereClause renders the accumulated conditions
+func (q *queryBuilder) whereClause() string {
+	if len(q.conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(q.conds, " AND ")
+}
+
+// rowQuerier is satisfied by both *sql.DB and *sql.Tx
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// queryTimeout bounds each repository call; zero disables the deadline
+type queryTimeout time.Duration
+
+// start derives the context for one repository call
+func (t queryTimeout) start(ctx context.Context) (context.Context, context.CancelFunc) {
+	if t <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, time.Duration(t))
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestQueryBuilder(t *testing.T) {
+	tests := []struct {
+		name      string
+		build     func(q *queryBuilder)
+		wantSet   string
+		wantWhere string
+		wantArgs  []any
+	}{
+		{
+			name:  "empty",
+			build: func(q *queryBuilder) {},
+		},
+		{
+			name: "conditions",
+			build: func(q *queryBuilder) {
+				q.where("author_id = " + q.arg(int64(3)))
+				q.where("deleted = FALSE")
+				q.where("title > " + q.arg("m"))
+			},
+			wantWhere: " WHERE author_id = $1 AND deleted = FALSE AND title > $2",
+			wantArgs:  []any{int64(3), "m"},
+		},
+		{
+			name: "placeholders continue from set to where",
+			build: func(q *queryBuilder) {
+				q.set("title", "New")
+				q.set("content", "")
+				q.where("id = " + q.arg(int64(7)))
+			},
+			wantSet:   "title = $1, content = $2",
+			wantWhere: " WHERE id = $3",
+			wantArgs:  []any{"New", "", int64(7)},
+		},
+		{
+			name: "values are bound, never inlined",
+			build: func(q *queryBuilder) {
+				q.where("title = " + q.arg("'; DROP TABLE blogs; --"))
+			},
+			wantWhere: " WHERE title = $1",
+			wantArgs:  []any{"'; DROP TABLE blogs; --"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := &queryBuilder{}
+			tt.build(q)
+			if got := q.setClause(); got != tt.wantSet {
+				t.Errorf("setClause = %q, want %q", got, tt.wantSet)
+			}
+			if got := q.whereClause(); got != tt.wantWhere {
+				t.Errorf("whereClause = %q, want %q", got, tt.wantWhere)
+			}
+			if !reflect.DeepEqual(q.args, tt.wantArgs) {
+				t.Errorf("args = %#v, want %#v", q.args, tt.wantArgs)
+			}
+		})
+	}
+}
@@ -0,0 +1,222 @@
+package repository
+
+import (
+	"errors"
+	"html"
+	"strings"
+	"unicode"
+)
+
+// ErrEmptySearch is returned when a search query has no searchable words
+var ErrEmptySearch = errors.New("search query must contain at least one word")
+
+// searchSort is the Sort value recorded in search cursors
+const searchSort = "rank"
+
+// Highlight delimiters requested from ts_headline. Private-use code points
+// never appear in real text, so the headline can be HTML-escaped first and
+// the delimiters swapped for <mark> tags afterwards.
+const (
+	highlightStart = "\uE000"
+	highlightStop  = "\uE001"
+)
+
+// headlineOptions configures ts_headline for content snippets
+const headlineOptions = `StartSel="` + highlightStart + `", StopSel="` + highlightStop + `"` +
+	", MaxFragments=2, MaxWords=30, MinWords=10, FragmentDelimiter=\" … \""
+
+// titleHeadlineOptions highlights every match in the (short) title
+const titleHeadlineOptions = `StartSel="` + highlightStart + `", StopSel="` + highlightStop + `", HighlightAll=true`
+
+// SearchTerm is one conjunct of a search query: a single word, a prefix or
+// a phrase of consecutive words
+type SearchTerm struct {
+	Words  []string
+	Prefix bool
+}
+
+// SearchQuery is a parsed full-text query. Every term must match.
+type SearchQuery struct {
+	Terms []SearchTerm
+}
+
+// ParseSearchQuery parses user input where "double quoted" text is a phrase
+// and a trailing * makes a word a prefix match. Punctuation is discarded so
+// the result is always safe to render as a tsquery.
+func ParseSearchQuery(input string) (SearchQuery, error) {
+	var q SearchQuery
+
+	rest := input
+	for rest != "" {
+		rest = strings.TrimLeftFunc(rest, unicode.IsSpace)
+		if rest == "" {
+			break
+		}
+
+		if rest[0] == '"' {
+			phrase, after, found := strings.Cut(rest[1:], `"`)
+			if !found {
+				after = ""
+			}
+			rest = after
+			if words := searchWords(phrase); len(words) > 0 {
+				q.Terms = append(q.Terms, SearchTerm{Words: words})
+			}
+			continue
+		}
+
+		end := strings.IndexFunc(rest, unicode.IsSpace)
+		if end < 0 {
+			end = len(rest)
+		}
+		token := rest[:end]
+		rest = rest[end:]
+
+		prefix := strings.HasSuffix(token, "*")
+		// Punctuation inside a token splits it into separate terms
+		words := searchWords(token)
+		for i, word := range words {
+			q.Terms = append(q.Terms, SearchTerm{Words: []string{word}, Prefix: prefix && i == len(words)-1})
+		}
+	}
+
+	if len(q.Terms) == 0 {
+		return q, ErrEmptySearch
+	}
+	return q, nil
+}
+
+// searchWords lowercases text and splits it into letter/digit runs
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// tsquery renders the query in to_tsquery syntax
+func (q SearchQuery) tsquery() string {
+	parts := make([]string, 0, len(q.Terms))
+	for _, term := range q.Terms {
+		if len(term.Words) > 1 {
+			parts = append(parts, "("+strings.Join(term.Words, " <-> ")+")")
+			continue
+		}
+		word := term.Words[0]
+		if term.Prefix {
+			word += ":*"
+		}
+		parts = append(parts, word)
+	}
+	return strings.Join(parts, " & ")
+}
+
+// renderHighlight HTML-escapes a headline and turns the delimiters into
+// <mark> tags
+func renderHighlight(headline string) string {
+	escaped := html.EscapeString(headline)
+	escaped = strings.ReplaceAll(escaped, highlightStart, "<mark>")
+	return strings.ReplaceAll(escaped, highlightStop, "</mark>")
+}
+
+// textSpan is the byte range of one word in a piece of text
+type textSpan struct {
+	start, end int
+	word       string
+}
+
+// wordSpans tokenizes text the same way searchWords does, keeping offsets
+func wordSpans(text string) []textSpan {
+	var spans []textSpan
+	start := -1
+	for i, r := range text {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if isWord && start < 0 {
+			start = i
+		} else if !isWord && start >= 0 {
+			spans = append(spans, textSpan{start, i, strings.ToLower(text[start:i])})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, textSpan{start, len(text), strings.ToLower(text[start:])})
+	}
+	return spans
+}
+
+// matchSpans returns which word positions match the query and which terms
+// were found. It approximates Postgres matching without stemming and is
+// used by the in-memory store.
+func (q SearchQuery) matchSpans(spans []textSpan) (marked []bool, found []bool) {
+	marked = make([]bool, len(spans))
+	found = make([]bool, len(q.Terms))
+	for t, term := range q.Terms {
+		for i := 0; i+len(term.Words) <= len(spans); i++ {
+			ok := true
+			for j, word := range term.Words {
+				candidate := spans[i+j].word
+				last := j == len(term.Words)-1
+				if candidate != word && !(term.Prefix && last && strings.HasPrefix(candidate, word)) {
+					ok = false
+					break
+				}
+			}
+			if ok {
+				found[t] = true
+				for j := range term.Words {
+					marked[i+j] = true
+				}
+			}
+		}
+	}
+	return marked, found
+}
+
+// highlightSpans wraps the marked words of text in delimiters, optionally
+// limited to a window of words around the first match
+func highlightSpans(text string, spans []textSpan, marked []bool, window int) string {
+	from, to := 0, len(spans)
+	if window > 0 && len(spans) > window {
+		first := 0
+		for i, m := range marked {
+			if m {
+				first = i
+				break
+			}
+		}
+		from = max(0, first-window/3)
+		to = min(len(spans), from+window)
+	}
+	if from >= to {
+		return ""
+	}
+
+	var b strings.Builder
+	pos := 0
+	if from > 0 {
+		pos = spans[from].start
+	}
+	for i := from; i < to; i++ {
+		span := spans[i]
+		b.WriteString(text[pos:span.start])
+		if marked[i] {
+			b.WriteString(highlightStart + text[span.start:span.end] + highlightStop)
+		} else {
+			b.WriteString(text[span.start:span.end])
+		}
+		pos = span.end
+	}
+	if to == len(spans) {
+		b.WriteString(text[pos:])
+	}
+
+	snippet := b.String()
+	if window > 0 {
+		if from > 0 {
+			snippet = "… " + snippet
+		}
+		if to < len(spans) {
+			snippet += " …"
+		}
+	}
+	return snippet
+}
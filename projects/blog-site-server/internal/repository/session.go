@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"blog-app/internal/models"
+	"context"
+	"database/sql"
+	"time"
+)
+
+type SessionRepository struct {
+	db      *sql.DB
+	timeout queryTimeout
+}
+
+// NewSessionRepository creates a repository whose calls are each cancelled
+// after timeout; zero disables the deadline
+func NewSessionRepository(db *sql.DB, timeout time.Duration) *SessionRepository {
+	return &SessionRepository{db: db, timeout: queryTimeout(timeout)}
+}
+
+// Create inserts a new login session
+func (r *SessionRepository) Create(ctx context.Context, session *models.Session) error {
+	ctx, cancel := r.timeout.start(ctx)
+	defer cancel()
+
+	query := `INSERT INTO sessions (token_hash, user_id, created_at, expires_at)
+			  VALUES ($1, $2, $3, $4)`
+
+	session.CreatedAt = time.Now()
+	_, err := r.db.ExecContext(ctx,
+		query,
+		session.TokenHash,
+		session.UserID,
+		session.CreatedAt,
+		session.ExpiresAt,
+	)
+	return classify(ctx, err)
+}
+
+// GetByTokenHash retrieves an unexpired session by its token hash
+func (r *SessionRepository) GetByTokenHash(ctx context.Context, tokenHash string) (*models.Session, error) {
+	ctx, cancel := r.timeout.start(ctx)
+	defer cancel()
+
+	query := `SELECT token_hash, user_id, created_at, expires_at
+			  FROM sessions WHERE token_hash = $1 AND expires_at > $2`
+
+	session := &models.Session{}
+	err := r.db.QueryRowContext(ctx, query, tokenHash, time.Now()).Scan(
+		&session.TokenHash,
+		&session.UserID,
+		&session.CreatedAt,
+		&session.ExpiresAt,
+	)
+	if err != nil {
+		return nil, classify(ctx, err)
+	}
+	return session, nil
+}
+
+// Delete removes a session by its token hash
+func (r *SessionRepository) Delete(ctx context.Context, tokenHash string) error {
+	ctx, cancel := r.timeout.start(ctx)
+	defer cancel()
+
+	query := `DELETE FROM sessions WHERE token_hash = $1`
+	_, err := r.db.ExecContext(ctx, query, tokenHash)
+	return classify(ctx, err)
+}
+
+// DeleteByUser removes every session of a user except the one with
+// exceptTokenHash, which may be empty to remove them all
+func (r *SessionRepository) DeleteByUser(ctx context.Context, userID int64, exceptTokenHash string) error {
+	ctx, cancel := r.timeout.start(ctx)
+	defer cancel()
+
+	query := `DELETE FROM sessions WHERE user_id = $1 AND token_hash <> $2`
+	_, err := r.db.ExecContext(ctx, query, userID, exceptTokenHash)
+	return classify(ctx, err)
+}
+
+// DeleteExpired removes every expired session
+func (r *SessionRepository) DeleteExpired(ctx context.Context) error {
+	ctx, cancel := r.timeout.start(ctx)
+	defer cancel()
+
+	query := `DELETE FROM sessions WHERE expires_at <= $1`
+	_, err := r.db.ExecContext(ctx, query, time.Now())
+	return classify(ctx, err)
+}
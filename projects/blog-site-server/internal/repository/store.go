@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"blog-app/internal/models"
+	"context"
+)
+
+// BlogStore is the persistence contract used by the blog handlers
+type BlogStore interface {
+	Create(ctx context.Context, blog *models.Blog) error
+	GetByID(ctx context.Context, id int64) (*models.Blog, error)
+	GetAll(ctx context.Context, filter BlogFilter, page Page) ([]*models.Blog, *Cursor, error)
+	Search(ctx context.Context, query SearchQuery, page Page) ([]*models.BlogSearchResult, *Cursor, error)
+	Update(ctx context.Context, blog *models.Blog, ifVersion int64) error
+	Patch(ctx context.Context, id int64, patch *models.BlogPatch, ifVersion int64) (*models.Blog, error)
+	Delete(ctx context.Context, id, ifVersion int64) error
+}
+
+// TagStore is the persistence contract used by the tag handlers. Tags are
+// attached to blog posts through BlogStore.
+type TagStore interface {
+	GetBySlug(ctx context.Context, slug string) (*models.Tag, error)
+	GetAll(ctx context.Context, page Page) ([]*models.Tag, *Cursor, error)
+}
+
+// UserStore is the persistence contract used by the user handlers
+type UserStore interface {
+	Create(ctx context.Context, user *models.User) error
+	GetByID(ctx context.Context, id int64) (*models.User, error)
+	GetByUsername(ctx context.Context, username string) (*models.User, error)
+	GetAll(ctx context.Context, page Page) ([]*models.User, *Cursor, error)
+	Update(ctx context.Context, user *models.User, ifVersion int64) error
+	Patch(ctx context.Context, id int64, patch *models.UserPatch, ifVersion int64) (*models.User, error)
+	UpdatePassword(ctx context.Context, id int64, passwordHash string) error
+	Delete(ctx context.Context, id, ifVersion int64) error
+}
+
+// CommentStore is the persistence contract used by the comment handlers
+type CommentStore interface {
+	Create(ctx context.Context, comment *models.Comment) error
+	GetByID(ctx context.Context, id int64) (*models.Comment, error)
+	GetByBlogID(ctx context.Context, blogID int64, page Page) ([]*models.Comment, *Cursor, error)
+	GetRootsByBlogID(ctx context.Context, blogID int64, page Page) ([]*models.Comment, *Cursor, error)
+	GetReplies(ctx context.Context, parentIDs []int64, maxDepth int) ([]*models.Comment, error)
+	Update(ctx context.Context, comment *models.Comment, ifVersion int64) error
+	Patch(ctx context.Context, id int64, patch *models.CommentPatch, ifVersion int64) (*models.Comment, error)
+	Delete(ctx context.Context, id, ifVersion int64) error
+}
+
+// SessionStore is the persistence contract for login sessions
+type SessionStore interface {
+	Create(ctx context.Context, session *models.Session) error
+	GetByTokenHash(ctx context.Context, tokenHash string) (*models.Session, error)
+	Delete(ctx context.Context, tokenHash string) error
+	DeleteExpired(ctx context.Context) error
+	DeleteByUser(ctx context.Context, userID int64, exceptTokenHash string) error
+}
+
+// Compile-time checks that both backends satisfy the store interfaces
+var (
+	_ BlogStore    = (*BlogRepository)(nil)
+	_ TagStore     = (*TagRepository)(nil)
+	_ UserStore    = (*UserRepository)(nil)
+	_ CommentStore = (*CommentRepository)(nil)
+	_ SessionStore = (*SessionRepository)(nil)
+
+	_ BlogStore    = (*MemoryBlogRepository)(nil)
+	_ TagStore     = (*MemoryTagRepository)(nil)
+	_ UserStore    = (*MemoryUserRepository)(nil)
+	_ CommentStore = (*MemoryCommentRepository)(nil)
+	_ SessionStore = (*MemorySessionRepository)(nil)
+)
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"blog-app/internal/models"
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// tagSort names the only ordering of tag listings, by slug
+const tagSort = "slug"
+
+// blogTagsColumn selects the sorted tag slugs of the current blogs row
+const blogTagsColumn = `ARRAY(SELECT t.slug FROM blog_tags bt JOIN tags t ON t.id = bt.tag_id
+			  WHERE bt.blog_id = blogs.id ORDER BY t.slug)`
+
+type TagRepository struct {
+	db      *sql.DB
+	timeout queryTimeout
+}
+
+// NewTagRepository creates a repository whose calls are each cancelled
+// after timeout; zero disables the deadline
+func NewTagRepository(db *sql.DB, timeout time.Duration) *TagRepository {
+	return &TagRepository{db: db, timeout: queryTimeout(timeout)}
+}
+
+// tagColumns is the select list matching scanTag, for tags aliased t
+const tagColumns = `t.id, t.slug, t.created_at, (SELECT count(*) FROM blog_tags WHERE tag_id = t.id)`
+
+// scanTag reads a row selected with tagColumns
+func scanTag(row rowScanner) (*models.Tag, error) {
+	tag := &models.Tag{}
+	if err := row.Scan(&tag.ID, &tag.Slug, &tag.CreatedAt, &tag.PostCount); err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+// GetBySlug retrieves a tag by its normalized slug
+func (r *TagRepository) GetBySlug(ctx context.Context, slug string) (*models.Tag, error) {
+	ctx, cancel := r.timeout.start(ctx)
+	defer cancel()
+
+	query := `SELECT ` + tagColumns + ` FROM tags t WHERE t.slug = $1`
+	tag, err := scanTag(r.db.QueryRowContext(ctx, query, slug))
+	return tag, classify(ctx, err)
+}
+
+// GetAll retrieves one page of the tags carrying at least one blog post,
+// ordered by slug
+func (r *TagRepository) GetAll(ctx context.Context, page Page) ([]*models.Tag, *Cursor, error) {
+	ctx, cancel := r.timeout.start(ctx)
+	defer cancel()
+
+	limit := page.limit()
+
+	q := &queryBuilder{}
+	q.where(`EXISTS (SELECT 1 FROM blog_tags WHERE tag_id = t.id)`)
+	if page.After != nil {
+		if !page.After.resumes(tagsKind, tagSort, 0) {
+			return nil, nil, ErrInvalidCursor
+		}
+		q.where(`t.slug > ` + q.arg(page.After.Text))
+	}
+	query := `SELECT ` + tagColumns + ` FROM tags t` +
+		q.whereClause() + ` ORDER BY t.slug LIMIT ` + q.arg(limit+1)
+
+	rows, err := r.db.QueryContext(ctx, query, q.args...)
+	if err != nil {
+		return nil, nil, classify(ctx, err)
+	}
+	defer rows.Close()
+
+	var tags []*models.Tag
+	for rows.Next() {
+		tag, err := scanTag(rows)
+		if err != nil {
+			return nil, nil, classify(ctx, err)
+		}
+		tags = append(tags, tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, classify(ctx, err)
+	}
+
+	tags, next := nextPage(tags, limit, tagCursor)
+	return tags, next, nil
+}
+
+// tagCursor returns the keyset position of a tag
+func tagCursor(tag *models.Tag) Cursor {
+	return Cursor{Kind: tagsKind, Sort: tagSort, Text: tag.Slug, ID: tag.ID}
+}
+
+// setBlogTags replaces the tags of a blog post, creating tags seen for the
+// first time. slugs must be normalized with models.NormalizeTags. Tags are
+// never deleted, so a tag created here cannot vanish before it is linked.
+func setBlogTags(ctx context.Context, tx *sql.Tx, blogID int64, slugs []string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM blog_tags WHERE blog_id = $1`, blogID); err != nil {
+		return err
+	}
+	if len(slugs) == 0 {
+		return nil
+	}
+
+	_, err := tx.ExecContext(ctx, `INSERT INTO tags (slug) SELECT unnest($1::text[]) ON CONFLICT (slug) DO NOTHING`, pq.Array(slugs))
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `INSERT INTO blog_tags (blog_id, tag_id) SELECT $1, id FROM tags WHERE slug = ANY($2)`, blogID, pq.Array(slugs))
+	return err
+}
@@ -2,46 +2,33 @@ package repository
 
 import (
 	"blog-app/internal/models"
+	"context"
 	"database/sql"
 	"time"
 )
 
 type UserRepository struct {
-	db *sql.DB
+	db      *sql.DB
+	timeout queryTimeout
 }
 
-func NewUserRepository(db *sql.DB) *UserRepository {
-	return &UserRepository{db: db}
+// NewUserRepository creates a repository whose calls are each cancelled
+// after timeout; zero disables the deadline
+func NewUserRepository(db *sql.DB, timeout time.Duration) *UserRepository {
+	return &UserRepository{db: db, timeout: queryTimeout(timeout)}
 }
 
-// Create inserts a new user into the database
-func (r *UserRepository) Create(user *models.User) error {
-	query := `INSERT INTO users (username, full_name, email, password_hash, role, bio, avatar_url, created_at, updated_at, is_active)
-			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id`
-
-	now := time.Now()
-	return r.db.QueryRow(
-		query,
-		user.Username,
-		user.FullName,
-		user.Email,
-		user.PasswordHash,
-		user.Role,
-		user.Bio,
-		user.AvatarURL,
-		now,
-		now,
-		true,
-	).Scan(&user.ID)
-}
+// userColumns is the select list matching scanUser
+const userColumns = `id, username, full_name, email, role, password_hash, bio, avatar_url, created_at, updated_at, is_active, version`
 
-// GetByID retrieves a user by their ID
-func (r *UserRepository) GetByID(id int64) (*models.User, error) {
-	query := `SELECT id, username, full_name, email, role, password_hash, bio, avatar_url, created_at, updated_at, is_active
-			  FROM users WHERE id = $1`
+// userListColumns is userColumns without the password hash, which listings
+// never need
+const userListColumns = `id, username, full_name, email, role, '' AS password_hash, bio, avatar_url, created_at, updated_at, is_active, version`
 
+// scanUser reads a row selected with userColumns or userListColumns
+func scanUser(row rowScanner) (*models.User, error) {
 	user := &models.User{}
-	err := r.db.QueryRow(query, id).Scan(
+	err := row.Scan(
 		&user.ID,
 		&user.Username,
 		&user.FullName,
@@ -53,6 +40,7 @@ func (r *UserRepository) GetByID(id int64) (*models.User, error) {
 		&user.CreatedAt,
 		&user.UpdatedAt,
 		&user.IsActive,
+		&user.Version,
 	)
 	if err != nil {
 		return nil, err
@@ -60,47 +48,115 @@ func (r *UserRepository) GetByID(id int64) (*models.User, error) {
 	return user, nil
 }
 
-// GetAll retrieves all users
-func (r *UserRepository) GetAll() ([]*models.User, error) {
-	query := `SELECT id, username, full_name, email, role, bio, avatar_url, created_at, updated_at, is_active
-			  FROM users ORDER BY created_at DESC`
+// Create inserts a new user into the database and fills in user with the
+// stored row
+func (r *UserRepository) Create(ctx context.Context, user *models.User) error {
+	ctx, cancel := r.timeout.start(ctx)
+	defer cancel()
+
+	query := `INSERT INTO users (username, full_name, email, password_hash, role, bio, avatar_url, created_at, updated_at, is_active)
+			  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING ` + userColumns
 
-	rows, err := r.db.Query(query)
+	now := time.Now()
+	stored, err := scanUser(r.db.QueryRowContext(ctx,
+		query,
+		user.Username,
+		user.FullName,
+		user.Email,
+		user.PasswordHash,
+		user.Role,
+		user.Bio,
+		user.AvatarURL,
+		now,
+		now,
+		true,
+	))
 	if err != nil {
-		return nil, err
+		return classify(ctx, err)
+	}
+	*user = *stored
+	return nil
+}
+
+// GetByID retrieves a user by their ID
+func (r *UserRepository) GetByID(ctx context.Context, id int64) (*models.User, error) {
+	ctx, cancel := r.timeout.start(ctx)
+	defer cancel()
+
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1`
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, id))
+	return user, classify(ctx, err)
+}
+
+// GetByUsername retrieves a user by their username
+func (r *UserRepository) GetByUsername(ctx context.Context, username string) (*models.User, error) {
+	ctx, cancel := r.timeout.start(ctx)
+	defer cancel()
+
+	query := `SELECT ` + userColumns + ` FROM users WHERE username = $1`
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, username))
+	return user, classify(ctx, err)
+}
+
+// GetAll retrieves one page of users, newest first, along with the cursor
+// for the next page
+func (r *UserRepository) GetAll(ctx context.Context, page Page) ([]*models.User, *Cursor, error) {
+	ctx, cancel := r.timeout.start(ctx)
+	defer cancel()
+
+	limit := page.limit()
+	if !page.After.resumes(usersKind, newestFirstSort, 0) {
+		return nil, nil, ErrInvalidCursor
+	}
+
+	query := `SELECT ` + userListColumns + ` FROM users
+			  ORDER BY created_at DESC, id DESC LIMIT $1`
+	args := []any{limit + 1}
+	if page.After != nil {
+		query = `SELECT ` + userListColumns + ` FROM users
+				 WHERE (created_at, id) < ($2, $3)
+				 ORDER BY created_at DESC, id DESC LIMIT $1`
+		args = append(args, page.After.Time, page.After.ID)
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, nil, classify(ctx, err)
 	}
 	defer rows.Close()
 
 	var users []*models.User
 	for rows.Next() {
-		user := &models.User{}
-		err := rows.Scan(
-			&user.ID,
-			&user.Username,
-			&user.FullName,
-			&user.Email,
-			&user.Role,
-			&user.Bio,
-			&user.AvatarURL,
-			&user.CreatedAt,
-			&user.UpdatedAt,
-			&user.IsActive,
-		)
+		user, err := scanUser(rows)
 		if err != nil {
-			return nil, err
+			return nil, nil, classify(ctx, err)
 		}
 		users = append(users, user)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, classify(ctx, err)
+	}
+
+	users, next := nextPage(users, limit, userCursor)
+	return users, next, nil
+}
 
-	return users, rows.Err()
+// userCursor returns the keyset position of a user
+func userCursor(user *models.User) Cursor {
+	return Cursor{Kind: usersKind, Sort: newestFirstSort, Time: user.CreatedAt, ID: user.ID}
 }
 
-// Update updates an existing user
-func (r *UserRepository) Update(user *models.User) error {
-	query := `UPDATE users SET full_name = $1, email = $2, role = $3, bio = $4, avatar_url = $5, updated_at = $6, is_active = $7
-			  WHERE id = $8`
+// Update updates an existing user if their stored version is ifVersion and
+// fills in user with the stored row
+func (r *UserRepository) Update(ctx context.Context, user *models.User, ifVersion int64) error {
+	ctx, cancel := r.timeout.start(ctx)
+	defer cancel()
 
-	_, err := r.db.Exec(
+	query := `UPDATE users SET full_name = $1, email = $2, role = $3, bio = $4, avatar_url = $5, updated_at = $6, is_active = $7,
+			  version = version + 1
+			  WHERE id = $8 AND ` + versionCond("$9") + ` RETURNING ` + userColumns
+
+	stored, err := scanUser(r.db.QueryRowContext(ctx,
 		query,
 		user.FullName,
 		user.Email,
@@ -110,13 +166,96 @@ func (r *UserRepository) Update(user *models.User) error {
 		time.Now(),
 		user.IsActive,
 		user.ID,
-	)
-	return err
+		ifVersion,
+	))
+	if err == sql.ErrNoRows {
+		return missOrConflict(ctx, r.db, "users", user.ID)
+	}
+	if err != nil {
+		return classify(ctx, err)
+	}
+	*user = *stored
+	return nil
+}
+
+// Patch applies a merge patch if the stored version is ifVersion, writing
+// only the touched columns, and returns the stored row
+func (r *UserRepository) Patch(ctx context.Context, id int64, patch *models.UserPatch, ifVersion int64) (*models.User, error) {
+	ctx, cancel := r.timeout.start(ctx)
+	defer cancel()
+
+	if patch.Empty() {
+		user, err := r.GetByID(ctx, id)
+		if err == nil && !versionMatches(user.Version, ifVersion) {
+			return nil, ErrVersionConflict
+		}
+		return user, err
+	}
+
+	q := &queryBuilder{}
+	if patch.FullName.Set {
+		q.set("full_name", patch.FullName.Value)
+	}
+	if patch.Email.Set {
+		q.set("email", patch.Email.Value)
+	}
+	if patch.Role.Set {
+		q.set("role", patch.Role.Value)
+	}
+	if patch.Bio.Set {
+		q.set("bio", patch.Bio.Value)
+	}
+	if patch.AvatarURL.Set {
+		q.set("avatar_url", patch.AvatarURL.Value)
+	}
+	if patch.IsActive.Set {
+		q.set("is_active", patch.IsActive.Value)
+	}
+	q.set("updated_at", time.Now())
+
+	query := `UPDATE users SET ` + q.setClause() + `, version = version + 1
+			  WHERE id = ` + q.arg(id) + ` AND ` + versionCond(q.arg(ifVersion)) + `
+			  RETURNING ` + userColumns
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, q.args...))
+	if err == sql.ErrNoRows {
+		return nil, missOrConflict(ctx, r.db, "users", id)
+	}
+	return user, classify(ctx, err)
+}
+
+// UpdatePassword replaces a user's password hash
+func (r *UserRepository) UpdatePassword(ctx context.Context, id int64, passwordHash string) error {
+	ctx, cancel := r.timeout.start(ctx)
+	defer cancel()
+
+	query := `UPDATE users SET password_hash = $1, updated_at = $2, version = version + 1 WHERE id = $3`
+
+	result, err := r.db.ExecContext(ctx, query, passwordHash, time.Now(), id)
+	if err != nil {
+		return classify(ctx, err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return classify(ctx, err)
+	} else if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
 }
 
-// Delete deletes a user by their ID
-func (r *UserRepository) Delete(id int64) error {
-	query := `DELETE FROM users WHERE id = $1`
-	_, err := r.db.Exec(query, id)
-	return err
+// Delete deletes a user by their ID if their stored version is ifVersion
+func (r *UserRepository) Delete(ctx context.Context, id, ifVersion int64) error {
+	ctx, cancel := r.timeout.start(ctx)
+	defer cancel()
+
+	query := `DELETE FROM users WHERE id = $1 AND ` + versionCond("$2")
+	result, err := r.db.ExecContext(ctx, query, id, ifVersion)
+	if err != nil {
+		return classify(ctx, err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return classify(ctx, err)
+	} else if n == 0 {
+		return missOrConflict(ctx, r.db, "users", id)
+	}
+	return nil
 }
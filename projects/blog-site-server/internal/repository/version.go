@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// AnyVersion makes a conditional write unconditional, as for If-Match: *
+const AnyVersion int64 = 0
+
+// ErrVersionConflict is returned when a conditional write names a version
+// that is no longer the stored one
+var ErrVersionConflict = errors.New("resource has been modified since the given version")
+
+// versionCond renders the version check of a conditional write. The
+// placeholder must be bound to the expected version or AnyVersion.
+func versionCond(placeholder string) string {
+	return fmt.Sprintf("(%[1]s::bigint = 0 OR version = %[1]s)", placeholder)
+}
+
+// versionMatches is the in-memory counterpart of versionCond
+func versionMatches(stored, ifVersion int64) bool {
+	return ifVersion == AnyVersion || stored == ifVersion
+}
+
+// missOrConflict explains why a conditional write matched no row: either the
+// row is gone or its version has moved on. table must come from code.
+func missOrConflict(ctx context.Context, db rowQuerier, table string, id int64) error {
+	var exists bool
+	err := db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM `+table+` WHERE id = $1)`, id).Scan(&exists)
+	if err != nil {
+		return classify(ctx, err)
+	}
+	if exists {
+		return ErrVersionConflict
+	}
+	return sql.ErrNoRows
+}
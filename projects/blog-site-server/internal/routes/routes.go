@@ -1,38 +1,112 @@
 package routes
 
 import (
+	"blog-app/internal/config"
 	"blog-app/internal/handlers"
+	"blog-app/internal/metrics"
+	"blog-app/internal/middleware"
+	"blog-app/internal/repository"
 	"net/http"
 )
 
-// Setup initializes all routes for the application
+// Options configures the middleware stack around the routes
+type Options struct {
+	Sessions repository.SessionStore
+	Users    repository.UserStore
+	Metrics  *metrics.Registry
+	// ServeMetrics registers GET /metrics for admins on this handler
+	ServeMetrics bool
+	CORS         config.CORS
+	MaxBodyBytes int64
+	RateLimit    config.RateLimit
+}
+
+// rateLimitGroup assigns a route to the config.RateLimit group limiting
+// it. Probes and metrics scrapes are never limited.
+func rateLimitGroup(method, route string) string {
+	switch {
+	case route == "/healthz" || route == "/readyz" || route == "/metrics":
+		return ""
+	case method == http.MethodPost && route == "/auth/login":
+		return "auth"
+	case method == http.MethodPost && route == "/users":
+		return "signup"
+	case method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions:
+		return "read"
+	}
+	return "write"
+}
+
+// Setup initializes all routes for the application and wraps them in the
+// middleware stack. Mutating routes require an authenticated user, which
+// middleware.Authenticate resolves from the bearer token.
 func Setup(
 	blogHandler *handlers.BlogHandler,
+	tagHandler *handlers.TagHandler,
 	userHandler *handlers.UserHandler,
 	commentHandler *handlers.CommentHandler,
-) *http.ServeMux {
+	authHandler *handlers.AuthHandler,
+	healthHandler *handlers.HealthHandler,
+	options Options,
+) http.Handler {
 	mux := http.NewServeMux()
 
+	// Probes and diagnostics
+	mux.HandleFunc("GET /healthz", healthHandler.Healthz)
+	mux.HandleFunc("GET /readyz", healthHandler.Readyz)
+	mux.Handle("GET /debug/status", middleware.RequireAuth(healthHandler.Status))
+	if options.ServeMetrics {
+		mux.Handle("GET /metrics", middleware.RequireAuth(healthHandler.Metrics))
+	}
+
+	// Auth routes
+	mux.HandleFunc("POST /auth/login", authHandler.Login)
+	mux.Handle("POST /auth/logout", middleware.RequireAuth(authHandler.Logout))
+
 	// Blog routes
-	mux.HandleFunc("POST /blogs", blogHandler.CreateBlog)
+	mux.Handle("POST /blogs", middleware.RequireAuth(blogHandler.CreateBlog))
 	mux.HandleFunc("GET /blogs", blogHandler.GetAllBlogs)
+	mux.HandleFunc("GET /blogs/search", blogHandler.SearchBlogs)
 	mux.HandleFunc("GET /blogs/{id}", blogHandler.GetBlog)
-	mux.HandleFunc("PUT /blogs/{id}", blogHandler.UpdateBlog)
-	mux.HandleFunc("DELETE /blogs/{id}", blogHandler.DeleteBlog)
+	mux.Handle("PUT /blogs/{id}", middleware.RequireAuth(blogHandler.UpdateBlog))
+	mux.Handle("PATCH /blogs/{id}", middleware.RequireAuth(blogHandler.PatchBlog))
+	mux.Handle("DELETE /blogs/{id}", middleware.RequireAuth(blogHandler.DeleteBlog))
+
+	// Tag routes
+	mux.HandleFunc("GET /tags", tagHandler.GetAllTags)
+	mux.HandleFunc("GET /tags/{slug}/blogs", tagHandler.GetTagBlogs)
 
 	// User routes
 	mux.HandleFunc("POST /users", userHandler.CreateUser)
 	mux.HandleFunc("GET /users", userHandler.GetAllUsers)
 	mux.HandleFunc("GET /users/{id}", userHandler.GetUser)
-	mux.HandleFunc("PUT /users/{id}", userHandler.UpdateUser)
-	mux.HandleFunc("DELETE /users/{id}", userHandler.DeleteUser)
+	mux.Handle("PUT /users/{id}", middleware.RequireAuth(userHandler.UpdateUser))
+	mux.Handle("PATCH /users/{id}", middleware.RequireAuth(userHandler.PatchUser))
+	mux.Handle("PUT /users/{id}/password", middleware.RequireAuth(userHandler.ChangePassword))
+	mux.Handle("DELETE /users/{id}", middleware.RequireAuth(userHandler.DeleteUser))
 
 	// Comment routes
-	mux.HandleFunc("POST /comments", commentHandler.CreateComment)
+	mux.Handle("POST /comments", middleware.RequireAuth(commentHandler.CreateComment))
 	mux.HandleFunc("GET /blogs/{blogID}/comments", commentHandler.GetCommentsForBlog)
 	mux.HandleFunc("GET /comments/{id}", commentHandler.GetComment)
-	mux.HandleFunc("PUT /comments/{id}", commentHandler.UpdateComment)
-	mux.HandleFunc("DELETE /comments/{id}", commentHandler.DeleteComment)
+	mux.Handle("PUT /comments/{id}", middleware.RequireAuth(commentHandler.UpdateComment))
+	mux.Handle("PATCH /comments/{id}", middleware.RequireAuth(commentHandler.PatchComment))
+	mux.Handle("DELETE /comments/{id}", middleware.RequireAuth(commentHandler.DeleteComment))
 
-	return mux
+	// Requests pass through these in order: metrics see every response,
+	// the request ID is assigned before anything logs, and panics are
+	// recovered inside the access log so they are recorded as 500s. Rate
+	// limits come last because they count authenticated users by account;
+	// Authenticate charges bad tokens to the client IP itself.
+	limiter := middleware.NewRateLimiter(options.RateLimit)
+	return middleware.Chain(mux,
+		middleware.Instrument(options.Metrics, mux),
+		middleware.RequestID,
+		middleware.AccessLog(mux),
+		middleware.Recover,
+		middleware.CORS(options.CORS),
+		middleware.LimitBody(options.MaxBodyBytes),
+		middleware.Authenticate(options.Sessions, options.Users, limiter),
+		limiter.Limit(mux, rateLimitGroup),
+	)
 }
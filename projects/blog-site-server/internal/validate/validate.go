@@ -0,0 +1,143 @@
+// Package validate checks struct fields against declarative `validate` tags.
+//
+// Rules are comma separated:
+//
+//	required   string must not be blank, number must not be zero
+//	min=N      string must have at least N characters
+//	max=N      string must have at most N characters
+//	email      string must be a bare email address
+//	url        string must be an absolute http or https URL
+//	oneof=a b  string must be one of the space separated values
+//
+// Apart from required, rules skip empty strings so optional fields can be
+// left out. Fields are reported under their JSON names.
+package validate
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FieldError describes one field that failed a rule
+type FieldError struct {
+	Field  string
+	Detail string
+}
+
+// Errors lists every failing field of a struct
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	parts := make([]string, len(e))
+	for i, fieldErr := range e {
+		parts[i] = fieldErr.Field + " " + fieldErr.Detail
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Struct validates the tagged fields of v, a struct or pointer to one, and
+// returns Errors listing every failure, or nil
+func Struct(v any) error {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	if value.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("validate: %T is not a struct", v))
+	}
+
+	var errs Errors
+	typ := value.Type()
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		tag, ok := field.Tag.Lookup("validate")
+		if !ok || !field.IsExported() {
+			continue
+		}
+		name := jsonName(field)
+		for _, rule := range strings.Split(tag, ",") {
+			if detail := check(rule, value.Field(i)); detail != "" {
+				errs = append(errs, FieldError{Field: name, Detail: detail})
+				// Report the first failing rule of each field only
+				break
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// jsonName returns the name the field is known by in request bodies
+func jsonName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+// check applies one rule, returning why the value fails it or ""
+func check(rule string, value reflect.Value) string {
+	name, param, _ := strings.Cut(rule, "=")
+
+	if name == "required" {
+		if value.IsZero() || (value.Kind() == reflect.String && strings.TrimSpace(value.String()) == "") {
+			return "is required"
+		}
+		return ""
+	}
+
+	if value.Kind() != reflect.String {
+		panic(fmt.Sprintf("validate: rule %q needs a string field", name))
+	}
+	s := value.String()
+	if s == "" {
+		return ""
+	}
+
+	switch name {
+	case "min":
+		if utf8.RuneCountInString(s) < mustAtoi(param) {
+			return "must be at least " + param + " characters"
+		}
+	case "max":
+		if utf8.RuneCountInString(s) > mustAtoi(param) {
+			return "must be at most " + param + " characters"
+		}
+	case "email":
+		addr, err := mail.ParseAddress(s)
+		if err != nil || addr.Address != s {
+			return "must be a valid email address"
+		}
+	case "url":
+		u, err := url.Parse(s)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "must be an absolute http or https URL"
+		}
+	case "oneof":
+		allowed := strings.Fields(param)
+		for _, option := range allowed {
+			if s == option {
+				return ""
+			}
+		}
+		return "must be one of " + strings.Join(allowed, ", ")
+	default:
+		panic(fmt.Sprintf("validate: unknown rule %q", name))
+	}
+	return ""
+}
+
+// mustAtoi parses a rule parameter; bad tags are programming errors
+func mustAtoi(param string) int {
+	n, err := strconv.Atoi(param)
+	if err != nil {
+		panic(fmt.Sprintf("validate: bad rule parameter %q", param))
+	}
+	return n
+}